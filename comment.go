@@ -0,0 +1,114 @@
+package njson
+
+import (
+	"strings"
+)
+
+/*
+ * Comments are the source comments attached to a dict member or an array
+ * item when comments are allowed by ParseOptions:
+ *
+ *	// Leading, on the lines before the item
+ *	"port": 8080, // Inline, on the line the item ends on
+ *	// Trailing, after the last item of a dict or array
+ *	}
+ *
+ * comments between a key and its value are Inline too. the comments in an
+ * empty dict or array are the Trailing comments of that dict or array.
+ */
+type Comments struct {
+	Leading  []string
+	Inline   []string
+	Trailing []string
+}
+
+// comment is a comment read before a token, the '//' or '/* */' is removed.
+type comment struct {
+	text     string
+	sameLine bool // on the line of the previous token
+}
+
+const (
+	commentLeading = iota
+	commentInline
+	commentTrailing
+)
+
+func commentText(s string) string {
+	if strings.HasPrefix(s, "//") {
+		return strings.TrimSpace(s[2:])
+	}
+	return strings.TrimSpace(strings.TrimSuffix(s[2:], "*/"))
+}
+
+func attachComments(element JsonElement, cs []*comment, where int) {
+	if len(cs) == 0 {
+		return
+	}
+
+	h, ok := element.(baseHolder)
+	if !ok {
+		return
+	}
+
+	b := h.base()
+	if b.comments == nil {
+		b.comments = &Comments{}
+	}
+
+	list := &b.comments.Leading
+	switch where {
+	case commentInline:
+		list = &b.comments.Inline
+	case commentTrailing:
+		list = &b.comments.Trailing
+	}
+
+	for _, c := range cs {
+		*list = append(*list, c.text)
+	}
+}
+
+// attachAfter attaches the comments read after element, the ones on its line are Inline.
+func attachAfter(element JsonElement, cs []*comment) {
+	for _, c := range cs {
+		where := commentTrailing
+		if c.sameLine {
+			where = commentInline
+		}
+		attachComments(element, []*comment{c}, where)
+	}
+}
+
+// splitComments separates the comments on the line of the previous token from the others.
+func splitComments(cs []*comment) ([]*comment, []*comment) {
+	var same, other []*comment
+
+	for _, c := range cs {
+		if c.sameLine && len(other) == 0 {
+			same = append(same, c)
+		} else {
+			other = append(other, c)
+		}
+	}
+
+	return same, other
+}
+
+// CommentsOf returns the comments attached to element, nil when it has none.
+func CommentsOf(element JsonElement) *Comments {
+	if h, ok := element.(baseHolder); ok {
+		return h.base().comments
+	}
+	return nil
+}
+
+// Comments returns the comments of the element a JSON Pointer refers to.
+func (self *JsonObject) Comments(pointer string) (*Comments, error) {
+	element, err := self.ResolvePointer(pointer)
+	if err != nil {
+		return nil, err
+	}
+
+	return CommentsOf(element), nil
+}
@@ -0,0 +1,183 @@
+package njson
+
+import (
+	"fmt"
+	"io"
+)
+
+/*
+ * Decoder reads json values one by one from an io.Reader.
+ *
+ * only the value being decoded is kept in memory. the input may hold several
+ * values in a row, and a large top level array can be walked item by item
+ * with OpenArray, More, Decode and CloseArray:
+ *
+ *	dec := njson.NewDecoder(r)
+ *	if err := dec.OpenArray(); err != nil { ... }
+ *	for dec.More() {
+ *		item, err := dec.Decode()
+ *		...
+ *	}
+ *	err := dec.CloseArray()
+ *
+ * a decoder made by NewLineDecoder reads NDJSON / JSON Lines, one value on
+ * each line, blank lines are skipped.
+ */
+type Decoder struct {
+	p      *parser
+	arrays []int // number of items read so far in each opened array
+	err    error
+
+	lines   bool // one value per line
+	line    int  // first line of the last value
+	endLine int  // last line of the last value
+}
+
+func NewDecoder(r io.Reader) *Decoder {
+	return defaultParseOptions.NewDecoder(r)
+}
+
+func NewLineDecoder(r io.Reader) *Decoder {
+	return defaultParseOptions.NewLineDecoder(r)
+}
+
+// Line returns the line the value read last by Decode starts on.
+func (self *Decoder) Line() int {
+	return self.line
+}
+
+func (self *Decoder) inArray() bool {
+	return len(self.arrays) > 0
+}
+
+// beginValue eats the ',' in front of the next item of an opened array.
+func (self *Decoder) beginValue() error {
+	if !self.inArray() {
+		return nil
+	}
+
+	p := self.p
+	top := len(self.arrays) - 1
+
+	if p.nowTok().Equals("]") {
+		return p.syntaxError("no more items in array")
+	}
+
+	if self.arrays[top] > 0 {
+		if !p.nowTok().Equals(",") {
+			return p.syntaxError("except ',' or ']'")
+		}
+		p.nextTok() // eat ','
+	}
+	self.arrays[top]++
+
+	return nil
+}
+
+// More reports whether there is another value in the current array or input.
+func (self *Decoder) More() bool {
+	if self.err != nil {
+		return false
+	}
+
+	tok := self.p.nowTok()
+
+	if tok.tokenType == _T_EOF {
+		return false
+	}
+
+	return !(self.inArray() && tok.Equals("]"))
+}
+
+/*
+ * Decode reads the next value. at the end of the input it returns io.EOF,
+ * after a syntax error every call returns that error.
+ */
+func (self *Decoder) Decode() (JsonElement, error) {
+	if self.err != nil {
+		return nil, self.err
+	}
+
+	if !self.inArray() && self.p.nowTok().tokenType == _T_EOF {
+		return nil, io.EOF
+	}
+
+	if err := self.beginValue(); err != nil {
+		self.err = err
+		return nil, err
+	}
+
+	start := self.p.nowTok()
+	if self.lines && start.lineno <= self.endLine && start.tokenType != _T_ERROR {
+		self.err = self.p.syntaxError("except a new line, each line holds one value")
+		return nil, self.err
+	}
+
+	// MaxElements is for each value
+	self.p.elements = 0
+
+	ele, err := self.p.parseValue()
+	if err != nil {
+		self.err = err
+		return nil, err
+	}
+
+	// the token after the value is not read yet
+	self.line, self.endLine = start.lineno, self.p.lexer._ln
+
+	if self.lines && self.endLine != self.line {
+		self.err = self.p.tokenError(start, ErrUnexpectedToken, fmt.Sprintf("value spans lines %d to %d, each line must hold one value", self.line, self.endLine))
+		return nil, self.err
+	}
+
+	return ele, nil
+}
+
+// OpenArray eats the '[' of the next value, its items are then read by Decode.
+func (self *Decoder) OpenArray() error {
+	if self.err != nil {
+		return self.err
+	}
+
+	if err := self.beginValue(); err != nil {
+		self.err = err
+		return err
+	}
+
+	if !self.p.nowTok().Equals("[") {
+		self.err = self.p.syntaxError("except '['")
+		return self.err
+	}
+	if err := self.p.enter(); err != nil {
+		self.err = err
+		return err
+	}
+	self.p.nextTok() // eat '['
+
+	self.arrays = append(self.arrays, 0)
+
+	return nil
+}
+
+// CloseArray eats the ']' of the array opened last.
+func (self *Decoder) CloseArray() error {
+	if self.err != nil {
+		return self.err
+	}
+
+	if !self.inArray() {
+		self.err = self.p.syntaxError("no array is opened")
+		return self.err
+	}
+
+	if !self.p.nowTok().Equals("]") {
+		self.err = self.p.syntaxError("except ']'")
+		return self.err
+	}
+	self.p.nextTok() // eat ']'
+	self.p.leave()
+
+	self.arrays = self.arrays[:len(self.arrays)-1]
+
+	return nil
+}
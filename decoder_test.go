@@ -0,0 +1,264 @@
+package njson
+
+import (
+	"errors"
+	"io"
+	"strings"
+	"testing"
+	"testing/iotest"
+)
+
+// readers giving the input in different pieces
+var pieceReaders = map[string]func(s string) io.Reader{
+	"whole":   func(s string) io.Reader { return strings.NewReader(s) },
+	"onebyte": func(s string) io.Reader { return iotest.OneByteReader(strings.NewReader(s)) },
+	"half":    func(s string) io.Reader { return iotest.HalfReader(strings.NewReader(s)) },
+	"dataerr": func(s string) io.Reader { return iotest.DataErrReader(strings.NewReader(s)) },
+	"empty":   func(s string) io.Reader { return &emptyReadsReader{r: strings.NewReader(s)} },
+}
+
+// emptyReadsReader returns no bytes and no error every other call
+type emptyReadsReader struct {
+	r     io.Reader
+	empty bool
+}
+
+func (self *emptyReadsReader) Read(p []byte) (int, error) {
+	self.empty = !self.empty
+	if self.empty {
+		return 0, nil
+	}
+	return self.r.Read(p)
+}
+
+func decodeAll(dec *Decoder) ([]string, error) {
+	values := []string{}
+	for {
+		v, err := dec.Decode()
+		if err == io.EOF {
+			return values, nil
+		}
+		if err != nil {
+			return values, err
+		}
+		s, _ := Dumps(v)
+		values = append(values, s)
+	}
+}
+
+func TestDecoder(t *testing.T) {
+	// long enough to cross a few buffer chunks
+	long := strings.Repeat("x", 3*jsonBufferChunk)
+	src := `{"a": 1} [1, 2]
+	"` + long + `" 3.5 null
+	{"b": {"c": [true, false]}}`
+	want := []string{`{"a":1}`, `[1,2]`, `"` + long + `"`, `3.5`, `null`, `{"b":{"c":[true,false]}}`}
+
+	for name, reader := range pieceReaders {
+		values, err := decodeAll(NewDecoder(reader(src)))
+		if err != nil {
+			t.Errorf("%s: %v", name, err)
+			continue
+		}
+		if strings.Join(values, " ") != strings.Join(want, " ") {
+			t.Errorf("%s: got %.200v", name, values)
+		}
+	}
+}
+
+func TestDecoderErrors(t *testing.T) {
+	dec := NewDecoder(strings.NewReader(`{"a": 1} {"b": } [1]`))
+
+	if _, err := dec.Decode(); err != nil {
+		t.Fatal(err)
+	}
+	_, err := dec.Decode()
+	var e *NJsonError
+	if !errors.As(err, &e) || e.Line != 1 || e.Column != 16 {
+		t.Fatalf("got %v", err)
+	}
+	// the error sticks
+	if _, again := dec.Decode(); again != err || dec.More() {
+		t.Errorf("got %v after an error", again)
+	}
+
+	// a reader that never returns anything
+	_, err = NewDecoder(neverReader{}).Decode()
+	if !errors.Is(err, ErrRead) || !errors.Is(err, io.ErrNoProgress) {
+		t.Errorf("got %v, want %v", err, io.ErrNoProgress)
+	}
+
+	// a read error in the middle of a value
+	r := io.MultiReader(strings.NewReader(`[1, 2`), iotest.TimeoutReader(strings.NewReader(`, 3]`)))
+	_, err = NewDecoder(iotest.OneByteReader(r)).Decode()
+	if !errors.Is(err, ErrRead) || !errors.Is(err, iotest.ErrTimeout) {
+		t.Errorf("got %v, want %v", err, iotest.ErrTimeout)
+	}
+
+	// the input ends in the middle of a value
+	_, err = NewDecoder(strings.NewReader(`[1, 2`)).Decode()
+	if !errors.Is(err, ErrUnexpectedEOF) {
+		t.Errorf("got %v, want %v", err, ErrUnexpectedEOF)
+	}
+}
+
+type neverReader struct{}
+
+func (neverReader) Read(p []byte) (int, error) {
+	return 0, nil
+}
+
+func TestDecoderArray(t *testing.T) {
+	src := `[{"id": 1}, [2, 3], "x", [], {"id": 5}] 7`
+
+	for name, reader := range pieceReaders {
+		dec := NewDecoder(reader(src))
+		if err := dec.OpenArray(); err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+
+		values := []string{}
+		for dec.More() {
+			if len(values) == 1 {
+				// an array in the array is opened too
+				if err := dec.OpenArray(); err != nil {
+					t.Fatalf("%s: %v", name, err)
+				}
+				for dec.More() {
+					v, err := dec.Decode()
+					if err != nil {
+						t.Fatalf("%s: %v", name, err)
+					}
+					values = append(values, "inner:"+v.String())
+				}
+				if err := dec.CloseArray(); err != nil {
+					t.Fatalf("%s: %v", name, err)
+				}
+				values = append(values, "closed")
+				continue
+			}
+
+			v, err := dec.Decode()
+			if err != nil {
+				t.Fatalf("%s: %v", name, err)
+			}
+			s, _ := Dumps(v)
+			values = append(values, s)
+		}
+		if err := dec.CloseArray(); err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+
+		got := strings.Join(values, " ")
+		if want := `{"id":1} inner:2 inner:3 closed "x" [] {"id":5}`; got != want {
+			t.Errorf("%s: got %s, want %s", name, got, want)
+		}
+
+		// the value after the array
+		rest, err := decodeAll(dec)
+		if err != nil || strings.Join(rest, " ") != "7" {
+			t.Errorf("%s: after the array %v %v", name, rest, err)
+		}
+	}
+}
+
+func TestDecoderArrayErrors(t *testing.T) {
+	cases := []struct {
+		src  string
+		read func(dec *Decoder) error
+	}{
+		{`{"a": 1}`, func(dec *Decoder) error { return dec.OpenArray() }},
+		{`[1 2]`, func(dec *Decoder) error {
+			dec.OpenArray()
+			dec.Decode()
+			_, err := dec.Decode()
+			return err
+		}},
+		{`[1]`, func(dec *Decoder) error {
+			dec.OpenArray()
+			dec.Decode()
+			_, err := dec.Decode()
+			return err
+		}},
+		{`[1, 2]`, func(dec *Decoder) error {
+			dec.OpenArray()
+			dec.Decode()
+			return dec.CloseArray()
+		}},
+		{`[1]`, func(dec *Decoder) error { return dec.CloseArray() }},
+		{`[1,`, func(dec *Decoder) error {
+			dec.OpenArray()
+			dec.Decode()
+			_, err := dec.Decode()
+			return err
+		}},
+	}
+
+	for _, c := range cases {
+		if err := c.read(NewDecoder(strings.NewReader(c.src))); err == nil {
+			t.Errorf("%s: no error", c.src)
+		}
+	}
+}
+
+func TestLineDecoder(t *testing.T) {
+	src := "{\"a\": 1}\n\n  [1, 2]\r\n\"x\"\n\n\n7\n"
+	want := []struct {
+		value string
+		line  int
+	}{
+		{`{"a":1}`, 1}, {`[1,2]`, 3}, {`"x"`, 4}, {`7`, 7},
+	}
+
+	for name, reader := range pieceReaders {
+		dec := NewLineDecoder(reader(src))
+		for _, w := range want {
+			v, err := dec.Decode()
+			if err != nil {
+				t.Fatalf("%s: %v", name, err)
+			}
+			if s, _ := Dumps(v); s != w.value || dec.Line() != w.line {
+				t.Errorf("%s: got %s on line %d, want %s on line %d", name, s, dec.Line(), w.value, w.line)
+			}
+		}
+		if _, err := dec.Decode(); err != io.EOF {
+			t.Errorf("%s: got %v, want io.EOF", name, err)
+		}
+	}
+
+	// one value on each line
+	for _, src := range []string{"1 2\n", "[1,\n2]\n", "{\"a\": 1} {\"b\": 2}\n"} {
+		if _, err := decodeAll(NewLineDecoder(strings.NewReader(src))); err == nil {
+			t.Errorf("%q: no error", src)
+		}
+	}
+}
+
+func TestJsonBuffer(t *testing.T) {
+	src := strings.Repeat("0123456789", jsonBufferChunk)
+
+	for name, reader := range pieceReaders {
+		buf := newJsonBuffer(reader(src))
+
+		got := []byte{}
+		for {
+			if s := buf.peekString(7); len(s) < 7 && len(got)+len(s) != len(src) {
+				t.Fatalf("%s: peekString(7) = %q at %d", name, s, buf.offset())
+			}
+			ch, ok := buf.peek(0)
+			if !ok {
+				break
+			}
+			got = append(got, ch)
+			buf.skip(1)
+		}
+
+		if string(got) != src || buf.offset() != len(src) || buf.Err() != nil {
+			t.Errorf("%s: read %d bytes, offset %d, err %v", name, len(got), buf.offset(), buf.Err())
+		}
+		// what was consumed is dropped
+		if cap(buf.data) > 4*jsonBufferChunk {
+			t.Errorf("%s: %d bytes kept", name, len(buf.data))
+		}
+	}
+}
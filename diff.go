@@ -0,0 +1,256 @@
+package njson
+
+import (
+	"strconv"
+)
+
+/*
+ * DiffOptions controls Diff. the zero value (or a nil pointer) gives add,
+ * remove and replace operations only.
+ */
+type DiffOptions struct {
+	// DetectMoves turns an array item removed at one index and added
+	// at another into a single move operation.
+	DetectMoves bool
+}
+
+// maxDiffCells bounds the LCS table of an array diff, longer arrays are compared item by item.
+const maxDiffCells = 1 << 22
+
+type differ struct {
+	opts  DiffOptions
+	patch Patch
+}
+
+func (self *differ) emit(op string, path Pointer, from Pointer, value JsonElement) {
+	self.patch = append(self.patch, PatchOp{Op: op, Path: path, From: from, Value: value})
+}
+
+func (self *differ) diff(path Pointer, a, b JsonElement) {
+	if equalElements(a, b, false) {
+		return
+	}
+
+	switch {
+	case a.Type() == ELE_DICT && b.Type() == ELE_DICT:
+		self.diffDict(path, a, b)
+	case a.Type() == ELE_ARRAY && b.Type() == ELE_ARRAY:
+		self.diffArray(path, a.ToElementArray(), b.ToElementArray())
+	default:
+		self.emit(PatchReplace, path, nil, b)
+	}
+}
+
+// diffDict removes and changes keys in the order of a, then adds the new keys in the order of b.
+func (self *differ) diffDict(path Pointer, a, b JsonElement) {
+	da, db := a.ToDict(), b.ToDict()
+
+	for _, k := range dictKeys(a) {
+		if vb, ok := db[k]; ok {
+			self.diff(path.Child(k), da[k], vb)
+		} else {
+			self.emit(PatchRemove, path.Child(k), nil, nil)
+		}
+	}
+
+	for _, k := range dictKeys(b) {
+		if _, ok := da[k]; !ok {
+			self.emit(PatchAdd, path.Child(k), nil, db[k])
+		}
+	}
+}
+
+/*
+ * lcs returns the pairs of indexes of a longest common subsequence of a and
+ * b, nil when the arrays are too long for the table.
+ */
+func lcs(a, b []JsonElement) [][2]int {
+	n, m := len(a), len(b)
+	if n == 0 || m == 0 || n*m > maxDiffCells {
+		return nil
+	}
+
+	// table[i*(m+1)+j] is the length of the LCS of a[i:] and b[j:]
+	table := make([]int32, (n+1)*(m+1))
+	at := func(i, j int) int32 { return table[i*(m+1)+j] }
+
+	for i := n - 1; i >= 0; i-- {
+		for j := m - 1; j >= 0; j-- {
+			switch {
+			case equalElements(a[i], b[j], false):
+				table[i*(m+1)+j] = at(i+1, j+1) + 1
+			case at(i+1, j) >= at(i, j+1):
+				table[i*(m+1)+j] = at(i+1, j)
+			default:
+				table[i*(m+1)+j] = at(i, j+1)
+			}
+		}
+	}
+
+	pairs := [][2]int{}
+	for i, j := 0, 0; i < n && j < m; {
+		switch {
+		case equalElements(a[i], b[j], false):
+			pairs = append(pairs, [2]int{i, j})
+			i++
+			j++
+		case at(i+1, j) >= at(i, j+1):
+			i++
+		default:
+			j++
+		}
+	}
+
+	return pairs
+}
+
+/*
+ * diffArray aligns the items of a and b on their longest common subsequence.
+ * an item of b is then kept, moved (DetectMoves), changed (paired with a
+ * removed item at the same place, diffed recursively) or new. the patch
+ * removes the items that are gone, moves, adds the new items and finally
+ * diffs the changed ones, every index is the one of the array at that step.
+ */
+func (self *differ) diffArray(path Pointer, a, b []JsonElement) {
+	// the common prefix and suffix are kept as they are
+	start := 0
+	for start < len(a) && start < len(b) && equalElements(a[start], b[start], false) {
+		start++
+	}
+	endA, endB := len(a), len(b)
+	for endA > start && endB > start && equalElements(a[endA-1], b[endB-1], false) {
+		endA--
+		endB--
+	}
+	a, b = a[start:endA], b[start:endB]
+
+	item := func(i int) Pointer { return path.Child(strconv.Itoa(start + i)) }
+
+	// src[j] is the item of a that becomes b[j], dst[i] where a[i] goes, -1 for none
+	src := make([]int, len(b))
+	dst := make([]int, len(a))
+	for j := range src {
+		src[j] = -1
+	}
+	for i := range dst {
+		dst[i] = -1
+	}
+	moved := make([]bool, len(b))
+	changed := make([]bool, len(b))
+
+	pairs := lcs(a, b)
+	for _, p := range pairs {
+		dst[p[0]], src[p[1]] = p[1], p[0]
+	}
+
+	if self.opts.DetectMoves {
+		for j := range b {
+			if src[j] >= 0 {
+				continue
+			}
+			for i := range a {
+				if dst[i] < 0 && equalElements(a[i], b[j], false) {
+					dst[i], src[j] = j, i
+					moved[j] = true
+					break
+				}
+			}
+		}
+	}
+
+	// pair what is left between two kept items
+	pairs = append(pairs, [2]int{len(a), len(b)})
+	lastA, lastB := 0, 0
+	for _, p := range pairs {
+		i, j := lastA, lastB
+		for {
+			for i < p[0] && dst[i] >= 0 {
+				i++
+			}
+			for j < p[1] && src[j] >= 0 {
+				j++
+			}
+			if i >= p[0] || j >= p[1] {
+				break
+			}
+			dst[i], src[j] = j, i
+			changed[j] = true
+		}
+		lastA, lastB = p[0]+1, p[1]+1
+	}
+
+	// removed items, from the last so the indexes of a stay valid
+	for i := len(a) - 1; i >= 0; i-- {
+		if dst[i] < 0 {
+			self.emit(PatchRemove, item(i), nil, nil)
+		}
+	}
+
+	// cur holds the items of a still in the array, by their index in a
+	cur := []int{}
+	for i := range a {
+		if dst[i] >= 0 {
+			cur = append(cur, i)
+		}
+	}
+
+	// moved items go right after the last item that comes before them in b
+	for j := range b {
+		if !moved[j] {
+			continue
+		}
+
+		from := 0
+		for from < len(cur) && cur[from] != src[j] {
+			from++
+		}
+		cur = append(cur[:from], cur[from+1:]...)
+
+		to := 0
+		for k, i := range cur {
+			if dst[i] < j {
+				to = k + 1
+			}
+		}
+		cur = append(cur, 0)
+		copy(cur[to+1:], cur[to:])
+		cur[to] = src[j]
+
+		if from != to {
+			self.emit(PatchMove, item(to), item(from), nil)
+		}
+	}
+
+	// the items are in the order of b now, add the new ones at their index
+	for j := range b {
+		if src[j] < 0 {
+			self.emit(PatchAdd, item(j), nil, b[j])
+		}
+	}
+
+	for j := range b {
+		if changed[j] {
+			self.diff(item(j), a[src[j]], b[j])
+		}
+	}
+}
+
+/*
+ * Diff returns a patch that turns a into b. dict members are compared by
+ * key, array items are aligned on their longest common subsequence. the
+ * values of the patch are the elements of b, they are not copied.
+ */
+func (self *DiffOptions) Diff(a, b JsonElement) Patch {
+	d := &differ{patch: Patch{}}
+	if self != nil {
+		d.opts = *self
+	}
+
+	d.diff(Pointer{}, nullIfNil(a), nullIfNil(b))
+
+	return d.patch
+}
+
+func Diff(a, b JsonElement) Patch {
+	return (*DiffOptions)(nil).Diff(a, b)
+}
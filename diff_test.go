@@ -0,0 +1,189 @@
+package njson
+
+import (
+	"math/rand"
+	"strconv"
+	"testing"
+)
+
+func TestDiff(t *testing.T) {
+	moves := &DiffOptions{DetectMoves: true}
+
+	cases := []struct {
+		opts *DiffOptions
+		a, b string
+		want string
+	}{
+		// the root
+		{nil, `1`, `1`, `[]`},
+		{nil, `1`, `"x"`, `[{"op":"replace","path":"","value":"x"}]`},
+		{nil, `{"a":1}`, `[1]`, `[{"op":"replace","path":"","value":[1]}]`},
+		{nil, `[1]`, `null`, `[{"op":"replace","path":"","value":null}]`},
+		{nil, `1`, `1.0`, `[{"op":"replace","path":"","value":1.0}]`},
+
+		// dicts
+		{nil, `{"a":1,"b":2}`, `{"b":3,"c":4}`, `[{"op":"remove","path":"/a"},{"op":"replace","path":"/b","value":3},{"op":"add","path":"/c","value":4}]`},
+		{nil, `{"a":{"b":[1]}}`, `{"a":{"b":[1,2]}}`, `[{"op":"add","path":"/a/b/1","value":2}]`},
+		{nil, `{"a/b":1,"c~d":2}`, `{"c~d":2}`, `[{"op":"remove","path":"/a~1b"}]`},
+
+		// the common prefix and suffix are kept
+		{nil, `[1,2,3,4,5]`, `[1,2,9,4,5]`, `[{"op":"replace","path":"/2","value":9}]`},
+		{nil, `[1,2,3]`, `[1,2,3,4]`, `[{"op":"add","path":"/3","value":4}]`},
+		{nil, `[1,2,3]`, `[0,1,2,3]`, `[{"op":"add","path":"/0","value":0}]`},
+		{nil, `[0,1,2,3]`, `[1,2,3]`, `[{"op":"remove","path":"/0"}]`},
+		{nil, `[1,2,3,4]`, `[1,4]`, `[{"op":"remove","path":"/2"},{"op":"remove","path":"/1"}]`},
+		{nil, `[1,2,1,2]`, `[1,2]`, `[{"op":"remove","path":"/3"},{"op":"remove","path":"/2"}]`},
+		{nil, `[{"a":1},2]`, `[{"a":2},2]`, `[{"op":"replace","path":"/0/a","value":2}]`},
+		{nil, `[1,2,3]`, `[]`, `[{"op":"remove","path":"/2"},{"op":"remove","path":"/1"},{"op":"remove","path":"/0"}]`},
+		{nil, `[]`, `[1,2]`, `[{"op":"add","path":"/0","value":1},{"op":"add","path":"/1","value":2}]`},
+
+		// moves
+		{nil, `[1,2,3]`, `[3,1,2]`, `[{"op":"remove","path":"/2"},{"op":"add","path":"/0","value":3}]`},
+		{moves, `[1,2,3]`, `[3,1,2]`, `[{"op":"move","path":"/0","from":"/2"}]`},
+		{moves, `[1,2,3]`, `[2,3,1]`, `[{"op":"move","path":"/2","from":"/0"}]`},
+		{moves, `[0,1,2,3,4]`, `[0,3,2,1,4]`, `[{"op":"move","path":"/3","from":"/2"},{"op":"move","path":"/3","from":"/1"}]`},
+		{moves, `[1,2,3]`, `[3,9,1]`, `[{"op":"remove","path":"/1"},{"op":"move","path":"/1","from":"/0"},{"op":"add","path":"/1","value":9}]`},
+	}
+
+	for _, c := range cases {
+		a, err := LoadsValue(c.a)
+		if err != nil {
+			t.Fatal(err)
+		}
+		b, err := LoadsValue(c.b)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		patch := c.opts.Diff(a, b)
+		if got, _ := Dumps(patch.Element()); got != c.want {
+			t.Errorf("Diff(%s, %s) %+v =\n%s\nwant\n%s", c.a, c.b, c.opts, got, c.want)
+		}
+
+		patched, err := patch.Apply(a)
+		if err != nil || !Equal(patched, b) {
+			t.Errorf("Diff(%s, %s) %+v does not apply: %v", c.a, c.b, c.opts, err)
+		}
+	}
+}
+
+// randomElement makes a small document, values repeat so arrays have common items
+func randomElement(r *rand.Rand, depth int) JsonElement {
+	n := r.Intn(10)
+	if depth <= 0 {
+		n = r.Intn(4)
+	}
+
+	switch n {
+	case 0:
+		return NewJsonIntegerElement(int64(r.Intn(4)))
+	case 1:
+		return NewJsonStringElement(strconv.Itoa(r.Intn(3)))
+	case 2:
+		return NewJsonNullElement()
+	case 3:
+		return NewJsonBoolElement(r.Intn(2) == 0)
+	case 4, 5, 6:
+		array := NewJsonArrayElement()
+		for i := r.Intn(7); i > 0; i-- {
+			array.Append(randomElement(r, depth-1))
+		}
+		return array
+	}
+
+	dict := NewJsonDictElement()
+	for i := r.Intn(4); i > 0; i-- {
+		dict.Set(string(rune('a'+r.Intn(4))), randomElement(r, depth-1))
+	}
+	return dict
+}
+
+// mutate returns a copy of element with a few random changes
+func mutate(r *rand.Rand, element JsonElement) JsonElement {
+	element = cloneElement(element)
+
+	switch e := element.(type) {
+	case *JsonArrayElement:
+		for k := r.Intn(4); k > 0; k-- {
+			n := len(e.ToElementArray())
+			switch r.Intn(4) {
+			case 0:
+				e.Insert(r.Intn(n+1), randomElement(r, 1))
+			case 1:
+				if n > 0 {
+					e.RemoveAt(r.Intn(n))
+				}
+			case 2:
+				if n > 1 {
+					i, j := r.Intn(n), r.Intn(n)
+					items := e.ToElementArray()
+					a, b := items[i], items[j]
+					e.SetAt(i, b)
+					e.SetAt(j, a)
+				}
+			case 3:
+				if n > 0 {
+					i := r.Intn(n)
+					e.SetAt(i, mutate(r, e.ToElementArray()[i]))
+				}
+			}
+		}
+	case *JsonDictElement:
+		for k := r.Intn(3); k > 0; k-- {
+			key := string(rune('a' + r.Intn(4)))
+			if v, ok := e.dict[key]; ok && r.Intn(2) == 0 {
+				e.Set(key, mutate(r, v))
+			} else if ok {
+				e.Delete(key)
+			} else {
+				e.Set(key, randomElement(r, 1))
+			}
+		}
+	default:
+		if r.Intn(2) == 0 {
+			return randomElement(r, 2)
+		}
+	}
+
+	return element
+}
+
+func TestDiffRandom(t *testing.T) {
+	r := rand.New(rand.NewSource(1))
+
+	rounds := 20000
+	if testing.Short() {
+		rounds = 2000
+	}
+
+	for n := 0; n < rounds; n++ {
+		a := randomElement(r, 3)
+		b := randomElement(r, 3)
+		if n%2 == 0 {
+			b = mutate(r, a)
+		}
+		before, _ := Dumps(a)
+
+		for _, opts := range []*DiffOptions{nil, {DetectMoves: true}} {
+			patch := opts.Diff(a, b)
+
+			// through its json form, like a patch sent elsewhere
+			parsed, err := ParsePatch(patch.Element())
+			if err != nil {
+				t.Fatalf("ParsePatch: %v", err)
+			}
+
+			patched, err := parsed.Apply(a)
+			if err != nil || !Equal(patched, b) {
+				sa, _ := Dumps(a)
+				sb, _ := Dumps(b)
+				sp, _ := Dumps(patch.Element())
+				t.Fatalf("Diff %+v\na = %s\nb = %s\npatch = %s\n%v", opts, sa, sb, sp, err)
+			}
+		}
+
+		if after, _ := Dumps(a); after != before {
+			t.Fatalf("Apply changed its document: %s, was %s", after, before)
+		}
+	}
+}
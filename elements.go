@@ -2,6 +2,7 @@ package njson
 
 import (
 	"fmt"
+	"sort"
 	"strings"
 )
 
@@ -18,7 +19,29 @@ type JsonElement interface {
 }
 
 type JsonBaseElement struct {
-	// nothing
+	comments *Comments
+	span     Span // where the element was read from, zero when it was not parsed
+}
+
+// baseHolder is implemented by every element that embeds JsonBaseElement.
+type baseHolder interface {
+	base() *JsonBaseElement
+}
+
+func (self *JsonBaseElement) base() *JsonBaseElement { return self }
+
+// cloneBase copies the comments too, so editing the ones of a copy does not change the original.
+func (self *JsonBaseElement) cloneBase() JsonBaseElement {
+	c := *self
+	if self.comments != nil {
+		comments := Comments{
+			Leading:  append([]string(nil), self.comments.Leading...),
+			Inline:   append([]string(nil), self.comments.Inline...),
+			Trailing: append([]string(nil), self.comments.Trailing...),
+		}
+		c.comments = &comments
+	}
+	return c
 }
 
 // Base method, sub class can overwrite these method.
@@ -46,6 +69,7 @@ func (self *JsonStringElement) String() string   { return fmt.Sprintf("%s", self
 type _JsonNumberSlot struct {
 	vfloat float64
 	vint   int64
+	raw    string // text of the number, only kept in UseNumber mode
 }
 
 type JsonIntegerElement struct {
@@ -57,7 +81,7 @@ type JsonIntegerElement struct {
 func (self *JsonIntegerElement) Raw() interface{}   { return self.ToInteger64() }
 func (self *JsonIntegerElement) ToInteger64() int64 { return self.slot.vint }
 func (self *JsonIntegerElement) Type() int          { return ELE_INTEGER }
-func (self *JsonIntegerElement) String() string     { return fmt.Sprint(self.slot.vint) }
+func (self *JsonIntegerElement) String() string     { return self.ToDecimalString() }
 
 type JsonFloatElement struct {
 	JsonBaseElement
@@ -68,7 +92,12 @@ type JsonFloatElement struct {
 func (self *JsonFloatElement) Raw() interface{}   { return self.ToFloat64() }
 func (self *JsonFloatElement) ToFloat64() float64 { return self.slot.vfloat }
 func (self *JsonFloatElement) Type() int          { return ELE_FLOAT }
-func (self *JsonFloatElement) String() string     { return fmt.Sprint(self.slot.vfloat) }
+func (self *JsonFloatElement) String() string {
+	if self.slot.raw != "" {
+		return self.slot.raw
+	}
+	return fmt.Sprint(self.slot.vfloat)
+}
 
 type JsonArrayElement struct {
 	JsonBaseElement
@@ -94,6 +123,84 @@ func (self *JsonArrayElement) ForEach(forfunc func(int, JsonElement)) {
 	}
 }
 
+func (self *JsonArrayElement) Len() int {
+	return len(self.array)
+}
+
+func (self *JsonArrayElement) checkIndex(index int, size int) error {
+	if index < 0 || index >= size {
+		return fmt.Errorf("index %d out of range [0, %d)", index, size)
+	}
+	return nil
+}
+
+// checkCycle fails when value is the array or holds it, the array would then hold itself.
+func (self *JsonArrayElement) checkCycle(value JsonElement) error {
+	if holds(value, self) {
+		return fmt.Errorf("can not insert an array into itself")
+	}
+	return nil
+}
+
+// Append adds the values at the end, none is added when one of them holds the array.
+func (self *JsonArrayElement) Append(values ...JsonElement) error {
+	for _, v := range values {
+		if err := self.checkCycle(v); err != nil {
+			return err
+		}
+	}
+
+	for _, v := range values {
+		self.add(v)
+	}
+	return nil
+}
+
+// add appends value without looking for a cycle, for arrays that are being built.
+func (self *JsonArrayElement) add(value JsonElement) {
+	self.array = append(self.array, nullIfNil(value))
+}
+
+// Insert puts value before the item at index, index == Len() appends it.
+func (self *JsonArrayElement) Insert(index int, value JsonElement) error {
+	if err := self.checkIndex(index, len(self.array)+1); err != nil {
+		return err
+	}
+	if err := self.checkCycle(value); err != nil {
+		return err
+	}
+
+	self.array = append(self.array, nil)
+	copy(self.array[index+1:], self.array[index:])
+	self.array[index] = nullIfNil(value)
+
+	return nil
+}
+
+func (self *JsonArrayElement) RemoveAt(index int) (JsonElement, error) {
+	if err := self.checkIndex(index, len(self.array)); err != nil {
+		return nil, err
+	}
+
+	removed := self.array[index]
+	self.array = append(self.array[:index], self.array[index+1:]...)
+
+	return removed, nil
+}
+
+func (self *JsonArrayElement) SetAt(index int, value JsonElement) error {
+	if err := self.checkIndex(index, len(self.array)); err != nil {
+		return err
+	}
+	if err := self.checkCycle(value); err != nil {
+		return err
+	}
+
+	self.array[index] = nullIfNil(value)
+
+	return nil
+}
+
 type JsonBoolElement struct {
 	JsonBaseElement
 
@@ -109,7 +216,10 @@ type JsonDictElement struct {
 	JsonBaseElement
 
 	dict map[string]JsonElement
-	keys []string /* for ForEach  */
+	keys []string                 /* for ForEach  */
+	dups map[string][]JsonElement /* every value of a repeated key, DuplicateKeepAll only */
+
+	keySpans map[string]Span /* where each key was read from */
 }
 
 func (self *JsonDictElement) Raw() interface{}               { return self.ToDict() }
@@ -124,6 +234,32 @@ func (self *JsonDictElement) String() string {
 
 	return "{" + strings.Join(item, ", ") + "}"
 }
+func (self *JsonDictElement) orderedKeys() []string {
+	if len(self.keys) == len(self.dict) {
+		return self.keys
+	}
+
+	// keys is out of sync with dict, keep the known order and add the rest
+	keys := []string{}
+	seen := map[string]bool{}
+
+	for _, k := range self.keys {
+		if _, ok := self.dict[k]; ok && !seen[k] {
+			keys = append(keys, k)
+			seen[k] = true
+		}
+	}
+
+	rest := []string{}
+	for k := range self.dict {
+		if !seen[k] {
+			rest = append(rest, k)
+		}
+	}
+	sort.Strings(rest)
+
+	return append(keys, rest...)
+}
 func (self *JsonDictElement) ForEach(forfunc func(string, JsonElement)) {
 	for i := 0; i < len(self.keys); i++ {
 		k := self.keys[i]
@@ -179,6 +315,112 @@ func (self *JsonDictElement) DGet(path string) JsonElement {
 	return ele
 }
 
+/*
+ * GetAll returns every value of key in the order they appear. it has more
+ * than one value only when the dict was read with DuplicateKeepAll.
+ */
+func (self *JsonDictElement) GetAll(key string) []JsonElement {
+	if values, ok := self.dups[key]; ok {
+		return values
+	}
+
+	if v, ok := self.dict[key]; ok {
+		return []JsonElement{v}
+	}
+	return nil
+}
+
+func (self *JsonDictElement) Len() int {
+	return len(self.dict)
+}
+
+func (self *JsonDictElement) Has(key string) bool {
+	_, ok := self.dict[key]
+	return ok
+}
+
+/*
+ * Set replaces the value of key in place, a new key is added at the end.
+ * it fails when value is the dict or holds it, the dict would then hold
+ * itself.
+ */
+func (self *JsonDictElement) Set(key string, value JsonElement) error {
+	if holds(value, self) {
+		return fmt.Errorf("can not insert a dict into itself")
+	}
+
+	self.set(key, value)
+	return nil
+}
+
+// set is Set without looking for a cycle, for dicts that are being built.
+func (self *JsonDictElement) set(key string, value JsonElement) {
+	if self.dict == nil {
+		self.dict = map[string]JsonElement{}
+	}
+
+	if _, ok := self.dict[key]; !ok {
+		self.keys = append(self.keys, key)
+	}
+	self.dict[key] = nullIfNil(value)
+	delete(self.dups, key)
+}
+
+// Delete removes key, it reports whether the key was there.
+func (self *JsonDictElement) Delete(key string) bool {
+	if _, ok := self.dict[key]; !ok {
+		return false
+	}
+
+	delete(self.dict, key)
+	delete(self.dups, key)
+	delete(self.keySpans, key)
+
+	for i, k := range self.keys {
+		if k == key {
+			self.keys = append(self.keys[:i], self.keys[i+1:]...)
+			break
+		}
+	}
+
+	return true
+}
+
+// Rename changes oldKey to newKey and keeps its position.
+func (self *JsonDictElement) Rename(oldKey, newKey string) error {
+	v, ok := self.dict[oldKey]
+	if !ok {
+		return fmt.Errorf("key '" + oldKey + "' is not exists")
+	}
+
+	if oldKey == newKey {
+		return nil
+	}
+
+	if _, ok := self.dict[newKey]; ok {
+		return fmt.Errorf("key '" + newKey + "' already exists")
+	}
+
+	delete(self.dict, oldKey)
+	self.dict[newKey] = v
+
+	if values, ok := self.dups[oldKey]; ok {
+		delete(self.dups, oldKey)
+		self.dups[newKey] = values
+	}
+	// the key text is not the one read any more
+	delete(self.keySpans, oldKey)
+
+	for i, k := range self.keys {
+		if k == oldKey {
+			self.keys[i] = newKey
+			break
+		}
+	}
+
+	return nil
+}
+
 type JsonNullElement struct {
 	JsonBaseElement
 }
@@ -188,46 +430,207 @@ func (self *JsonNullElement) String() string { return "null" }
 
 // ElementFactory
 
+func NewJsonDictElement() *JsonDictElement {
+	return &JsonDictElement{
+		dict: map[string]JsonElement{},
+		keys: []string{},
+	}
+}
+
+func NewJsonArrayElement(items ...JsonElement) *JsonArrayElement {
+	array := &JsonArrayElement{
+		array: []JsonElement{},
+	}
+	for _, item := range items {
+		array.add(item)
+	}
+
+	return array
+}
+
 /*
- * this function can create object from the type of value
+ * holds reports whether container is element itself or is found inside it.
+ * a dict or array that holds itself can not be written, compared or copied.
  */
-func NewJsonElementByValue(value interface{}) JsonElement {
-	switch value.(type) {
+func holds(element JsonElement, container JsonElement) bool {
+	seen := map[JsonElement]bool{}
 
-	case int:
-		return &JsonIntegerElement{
-			slot: &_JsonNumberSlot{
-				vint: int64(value.(int)),
-			},
+	var walk func(element JsonElement) bool
+	walk = func(element JsonElement) bool {
+		if element == nil {
+			return false
 		}
 
-	case int64:
-		return &JsonIntegerElement{
-			slot: &_JsonNumberSlot{
-				vint: value.(int64),
-			},
+		switch e := element.(type) {
+		case *JsonArrayElement:
+			if JsonElement(e) == container {
+				return true
+			}
+			if seen[e] {
+				return false
+			}
+			seen[e] = true
+		case *JsonDictElement:
+			if JsonElement(e) == container {
+				return true
+			}
+			if seen[e] {
+				return false
+			}
+			seen[e] = true
+
+			for _, values := range e.dups {
+				for _, v := range values {
+					if walk(v) {
+						return true
+					}
+				}
+			}
 		}
 
-	case float32:
-		return &JsonIntegerElement{
-			slot: &_JsonNumberSlot{
-				vfloat: float64(value.(float32)),
-			},
+		switch element.Type() {
+		case ELE_ARRAY:
+			for _, v := range element.ToElementArray() {
+				if walk(v) {
+					return true
+				}
+			}
+		case ELE_DICT:
+			for _, v := range element.ToDict() {
+				if walk(v) {
+					return true
+				}
+			}
 		}
 
-	case float64:
-		return &JsonFloatElement{
-			slot: &_JsonNumberSlot{
-				vfloat: value.(float64),
-			},
+		return false
+	}
+
+	return walk(element)
+}
+
+func NewJsonNullElement() *JsonNullElement {
+	return &JsonNullElement{}
+}
+
+func NewJsonBoolElement(value bool) *JsonBoolElement {
+	return &JsonBoolElement{
+		value: value,
+	}
+}
+
+func nullIfNil(element JsonElement) JsonElement {
+	if element == nil {
+		return NewJsonNullElement()
+	}
+	return element
+}
+
+func NewJsonIntegerElement(value int64) *JsonIntegerElement {
+	return &JsonIntegerElement{
+		slot: &_JsonNumberSlot{
+			vint: value,
+		},
+	}
+}
+
+func NewJsonFloatElement(value float64) *JsonFloatElement {
+	return &JsonFloatElement{
+		slot: &_JsonNumberSlot{
+			vfloat: value,
+		},
+	}
+}
+
+func NewJsonStringElement(value string) *JsonStringElement {
+	return &JsonStringElement{
+		value: value,
+	}
+}
+
+/*
+ * cloneElement returns a deep copy of element, comments and spans are kept.
+ * dicts and arrays of other implementations are copied into njson elements,
+ * their scalars are shared.
+ */
+func cloneElement(element JsonElement) JsonElement {
+	switch e := element.(type) {
+
+	case *JsonDictElement:
+		c := &JsonDictElement{
+			JsonBaseElement: e.cloneBase(),
+			dict:            make(map[string]JsonElement, len(e.dict)),
+			keys:            append([]string{}, e.keys...),
+		}
+		for k, v := range e.dict {
+			c.dict[k] = cloneElement(v)
+		}
+		if e.dups != nil {
+			c.dups = map[string][]JsonElement{}
+			for k, values := range e.dups {
+				for _, v := range values {
+					c.dups[k] = append(c.dups[k], cloneElement(v))
+				}
+			}
+		}
+		if e.keySpans != nil {
+			c.keySpans = make(map[string]Span, len(e.keySpans))
+			for k, span := range e.keySpans {
+				c.keySpans[k] = span
+			}
 		}
+		return c
 
-	case string:
-		return &JsonStringElement{
-			value: value.(string),
+	case *JsonArrayElement:
+		c := &JsonArrayElement{
+			JsonBaseElement: e.cloneBase(),
+			array:           make([]JsonElement, len(e.array)),
 		}
+		for i, v := range e.array {
+			c.array[i] = cloneElement(v)
+		}
+		return c
+
+	case *JsonIntegerElement:
+		slot := *e.slot
+		return &JsonIntegerElement{JsonBaseElement: e.cloneBase(), slot: &slot}
+	case *JsonFloatElement:
+		slot := *e.slot
+		return &JsonFloatElement{JsonBaseElement: e.cloneBase(), slot: &slot}
+	case *JsonStringElement:
+		return &JsonStringElement{JsonBaseElement: e.cloneBase(), value: e.value}
+	case *JsonBoolElement:
+		return &JsonBoolElement{JsonBaseElement: e.cloneBase(), value: e.value}
+	case *JsonNullElement:
+		return &JsonNullElement{JsonBaseElement: e.cloneBase()}
+	}
 
+	switch element.Type() {
+	case ELE_DICT:
+		c := NewJsonDictElement()
+		for _, k := range dictKeys(element) {
+			c.set(k, cloneElement(element.ToDict()[k]))
+		}
+		return c
+	case ELE_ARRAY:
+		c := NewJsonArrayElement()
+		for _, v := range element.ToElementArray() {
+			c.add(cloneElement(v))
+		}
+		return c
 	}
 
-	return nil
+	return element
+}
+
+/*
+ * this function can create object from the type of value,
+ * it returns nil when value can not be marshaled, use Marshal to get the error.
+ */
+func NewJsonElementByValue(value interface{}) JsonElement {
+	ele, err := Marshal(value)
+	if err != nil {
+		return nil
+	}
+	return ele
 }
@@ -0,0 +1,59 @@
+package njson
+
+import (
+	"testing"
+)
+
+func TestInsertCycle(t *testing.T) {
+	doc, err := LoadsValue(`{"a": {"b": [1, {"c": []}]}, "d": 2}`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	root := doc.(*JsonDictElement)
+	a := root.dict["a"].(*JsonDictElement)
+	b := a.dict["b"].(*JsonArrayElement)
+	c := b.array[1].(*JsonDictElement).dict["c"].(*JsonArrayElement)
+
+	errs := []error{
+		root.Set("self", root),
+		a.Set("up", root),
+		b.Append(NewJsonIntegerElement(3), a),
+		b.Insert(0, b),
+		b.SetAt(0, root),
+		c.Append(NewJsonArrayElement(NewJsonArrayElement(b))),
+		c.Insert(0, a),
+	}
+	for i, err := range errs {
+		if err == nil {
+			t.Errorf("insert %d made a cycle", i)
+		}
+	}
+
+	// nothing was inserted
+	if s, _ := Dumps(doc); s != `{"a":{"b":[1,{"c":[]}]},"d":2}` {
+		t.Errorf("got %s", s)
+	}
+
+	// the same element in two places is not a cycle
+	errs = []error{
+		root.Set("e", b),
+		c.Append(root.dict["d"], root.dict["d"]),
+		root.Set("f", NewJsonArrayElement(b, b)),
+	}
+	for i, err := range errs {
+		if err != nil {
+			t.Errorf("insert %d: %v", i, err)
+		}
+	}
+	if s, _ := Dumps(doc); s != `{"a":{"b":[1,{"c":[2,2]}]},"d":2,"e":[1,{"c":[2,2]}],"f":[[1,{"c":[2,2]}],[1,{"c":[2,2]}]]}` {
+		t.Errorf("got %s", s)
+	}
+	if !Equal(doc, cloneElement(doc)) {
+		t.Errorf("a copy differs")
+	}
+
+	// b is in e and f now, a cycle through them is found too
+	if err := c.Append(root.dict["f"]); err == nil {
+		t.Errorf("insert made a cycle")
+	}
+}
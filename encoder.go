@@ -0,0 +1,499 @@
+package njson
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+const hexDigits = "0123456789abcdef"
+
+/*
+ * EncodeOptions controls the layout of Dump / Dumps output.
+ * the zero value (or a nil pointer) gives compact output.
+ */
+type EncodeOptions struct {
+	// Indent is written once per nesting level, output is indented
+	// when Indent or Prefix is not empty.
+	Indent string
+
+	// Prefix is written at the beginning of every line but the first.
+	Prefix string
+
+	// SortKeys writes dict keys in sorted order instead of insertion order.
+	SortKeys bool
+
+	// CompactArrays writes arrays that hold only scalars on one line.
+	CompactArrays bool
+
+	/*
+	 * MaxWidth is the width of indented output: an array or dict that fits
+	 * in the rest of the line is written on one line, like [1, {"a": 2}].
+	 * with CompactArrays, arrays of scalars too long for it are wrapped over
+	 * several lines. 0 means no limit, and only CompactArrays joins lines.
+	 */
+	MaxWidth int
+}
+
+type encoder struct {
+	w    *bufio.Writer
+	opts EncodeOptions
+
+	pretty bool
+	col    int
+}
+
+func newEncoder(w io.Writer, opts *EncodeOptions) *encoder {
+	enc := &encoder{
+		w: bufio.NewWriter(w),
+	}
+
+	if opts != nil {
+		enc.opts = *opts
+		enc.pretty = opts.Indent != "" || opts.Prefix != ""
+	}
+
+	return enc
+}
+
+func (self *encoder) write(s string) {
+	self.w.WriteString(s)
+
+	if i := strings.LastIndexByte(s, '\n'); i >= 0 {
+		self.col = utf8.RuneCountInString(s[i+1:])
+	} else {
+		self.col += utf8.RuneCountInString(s)
+	}
+}
+
+func (self *encoder) newline(depth int) {
+	if !self.pretty {
+		return
+	}
+	self.write("\n" + self.opts.Prefix + strings.Repeat(self.opts.Indent, depth))
+}
+
+func quoteString(s string) string {
+	var w strings.Builder
+	w.WriteByte('"')
+
+	start := 0
+	for i := 0; i < len(s); {
+		c := s[i]
+
+		if c < utf8.RuneSelf {
+			if c >= 0x20 && c != '"' && c != '\\' {
+				i++
+				continue
+			}
+
+			w.WriteString(s[start:i])
+
+			switch c {
+			case '"', '\\':
+				w.WriteByte('\\')
+				w.WriteByte(c)
+			case '\n':
+				w.WriteString(`\n`)
+			case '\r':
+				w.WriteString(`\r`)
+			case '\t':
+				w.WriteString(`\t`)
+			case '\b':
+				w.WriteString(`\b`)
+			case '\f':
+				w.WriteString(`\f`)
+			default:
+				w.WriteString(`\u00`)
+				w.WriteByte(hexDigits[c>>4])
+				w.WriteByte(hexDigits[c&0xf])
+			}
+
+			i++
+			start = i
+			continue
+		}
+
+		r, size := utf8.DecodeRuneInString(s[i:])
+		if r == utf8.RuneError && size == 1 {
+			w.WriteString(s[start:i])
+
+			if sr, ok := decodeWTF8(s[i:]); ok {
+				// a lone surrogate kept by SurrogateWTF8, escape it again
+				fmt.Fprintf(&w, `\u%04x`, sr)
+				size = 3
+			} else {
+				// invalid utf-8 can not appear in a json text
+				w.WriteString(`\ufffd`)
+			}
+
+			i += size
+			start = i
+			continue
+		}
+
+		i += size
+	}
+
+	w.WriteString(s[start:])
+	w.WriteByte('"')
+
+	return w.String()
+}
+
+// decodeWTF8 decodes a surrogate code point encoded by encodeWTF8.
+func decodeWTF8(s string) (rune, bool) {
+	if len(s) < 3 || s[0] != 0xed || s[1] < 0xa0 || s[1] > 0xbf || s[2]&0xc0 != 0x80 {
+		return 0, false
+	}
+	return rune(s[0]&0x0f)<<12 | rune(s[1]&0x3f)<<6 | rune(s[2]&0x3f), true
+}
+
+func formatFloat(f float64) (string, error) {
+	if math.IsNaN(f) || math.IsInf(f, 0) {
+		return "", fmt.Errorf("unsupported float value : %v", f)
+	}
+
+	format := byte('f')
+	if abs := math.Abs(f); abs != 0 && (abs < 1e-6 || abs >= 1e21) {
+		format = 'e'
+	}
+
+	s := strconv.FormatFloat(f, format, -1, 64)
+
+	// keep the value a float when it is loaded again
+	if !strings.ContainsAny(s, ".e") {
+		s += ".0"
+	}
+
+	return s, nil
+}
+
+func isScalar(element JsonElement) bool {
+	t := element.Type()
+	return t != ELE_ARRAY && t != ELE_DICT
+}
+
+func formatScalar(element JsonElement) (string, error) {
+	// numbers read in UseNumber mode are written back as they were
+	if raw := lexemeOf(element); raw != "" {
+		return raw, nil
+	}
+
+	switch element.Type() {
+
+	case ELE_STRING:
+		return quoteString(element.ToString()), nil
+	case ELE_INTEGER:
+		return strconv.FormatInt(element.ToInteger64(), 10), nil
+	case ELE_FLOAT:
+		return formatFloat(element.ToFloat64())
+	case ELE_BOOL:
+		return strconv.FormatBool(element.ToBool()), nil
+	case ELE_NULL:
+		return "null", nil
+	}
+
+	return "", fmt.Errorf("unsupported element : %s", element.String())
+}
+
+func (self *encoder) encode(element JsonElement, depth int) error {
+	if element == nil {
+		return fmt.Errorf("can not encode nil element")
+	}
+
+	if self.pretty && self.opts.MaxWidth > 0 && !isScalar(element) {
+		if s, ok, err := self.oneLine(element, self.opts.MaxWidth-self.col); err != nil {
+			return err
+		} else if ok {
+			self.write(s)
+			return nil
+		}
+	}
+
+	switch element.Type() {
+
+	case ELE_ARRAY:
+		return self.encodeArray(element.ToElementArray(), depth)
+
+	case ELE_DICT:
+		return self.encodeDict(element, depth)
+	}
+
+	s, err := formatScalar(element)
+	if err != nil {
+		return err
+	}
+	self.write(s)
+
+	return nil
+}
+
+func (self *encoder) encodeArray(array []JsonElement, depth int) error {
+	if len(array) == 0 {
+		self.write("[]")
+		return nil
+	}
+
+	if self.pretty && self.opts.CompactArrays {
+		if items, ok, err := self.scalarItems(array); err != nil {
+			return err
+		} else if ok {
+			self.encodeCompactArray(items, depth)
+			return nil
+		}
+	}
+
+	self.write("[")
+	for i, item := range array {
+		if i > 0 {
+			self.write(",")
+		}
+		self.newline(depth + 1)
+		if err := self.encode(item, depth+1); err != nil {
+			return err
+		}
+	}
+	self.newline(depth)
+	self.write("]")
+
+	return nil
+}
+
+// scalarItems formats every item of array, ok is false if any item is not a scalar.
+func (self *encoder) scalarItems(array []JsonElement) ([]string, bool, error) {
+	items := make([]string, len(array))
+
+	for i, item := range array {
+		if item == nil {
+			return nil, false, fmt.Errorf("can not encode nil element")
+		}
+		if !isScalar(item) {
+			return nil, false, nil
+		}
+
+		s, err := formatScalar(item)
+		if err != nil {
+			return nil, false, err
+		}
+		items[i] = s
+	}
+
+	return items, true, nil
+}
+
+func (self *encoder) encodeCompactArray(items []string, depth int) {
+	line := "[" + strings.Join(items, ", ") + "]"
+	width := self.opts.MaxWidth
+
+	if width <= 0 || self.col+utf8.RuneCountInString(line) <= width {
+		self.write(line)
+		return
+	}
+
+	// too long for one line, fill each line up to the width
+	self.write("[")
+	self.newline(depth + 1)
+	lineStart := true
+
+	for i, item := range items {
+		sep := ""
+		if i < len(items)-1 {
+			sep = ","
+		}
+
+		if !lineStart {
+			if self.col+1+utf8.RuneCountInString(item+sep) > width {
+				self.newline(depth + 1)
+			} else {
+				self.write(" ")
+			}
+		}
+
+		self.write(item + sep)
+		lineStart = false
+	}
+
+	self.newline(depth)
+	self.write("]")
+}
+
+/*
+ * oneLine formats element on a single line, ok is false when it takes more
+ * than width characters. it stops as soon as the width is passed.
+ */
+func (self *encoder) oneLine(element JsonElement, width int) (string, bool, error) {
+	var w strings.Builder
+	size := 0
+
+	put := func(s string) bool {
+		w.WriteString(s)
+		size += utf8.RuneCountInString(s)
+		return size <= width
+	}
+
+	var walk func(element JsonElement) (bool, error)
+	walk = func(element JsonElement) (bool, error) {
+		if element == nil {
+			return false, fmt.Errorf("can not encode nil element")
+		}
+
+		switch element.Type() {
+
+		case ELE_ARRAY:
+			if !put("[") {
+				return false, nil
+			}
+			for i, item := range element.ToElementArray() {
+				if i > 0 && !put(", ") {
+					return false, nil
+				}
+				if ok, err := walk(item); !ok || err != nil {
+					return false, err
+				}
+			}
+			return put("]"), nil
+
+		case ELE_DICT:
+			dict := element.ToDict()
+			if !put("{") {
+				return false, nil
+			}
+			for i, k := range self.keys(element) {
+				if i > 0 && !put(", ") {
+					return false, nil
+				}
+				if !put(quoteString(k) + ": ") {
+					return false, nil
+				}
+				if ok, err := walk(dict[k]); !ok || err != nil {
+					return false, err
+				}
+			}
+			return put("}"), nil
+		}
+
+		s, err := formatScalar(element)
+		if err != nil {
+			return false, err
+		}
+		return put(s), nil
+	}
+
+	ok, err := walk(element)
+	if !ok || err != nil {
+		return "", false, err
+	}
+	return w.String(), true, nil
+}
+
+// keys returns the keys of a dict in the order they are written.
+func (self *encoder) keys(element JsonElement) []string {
+	keys := dictKeys(element)
+
+	if self.opts.SortKeys {
+		sorted := make([]string, len(keys))
+		copy(sorted, keys)
+		sort.Strings(sorted)
+		keys = sorted
+	}
+
+	return keys
+}
+
+func (self *encoder) encodeDict(element JsonElement, depth int) error {
+	dict := element.ToDict()
+	keys := self.keys(element)
+
+	if len(keys) == 0 {
+		self.write("{}")
+		return nil
+	}
+
+	colon := ":"
+	if self.pretty {
+		colon = ": "
+	}
+
+	self.write("{")
+	for i, k := range keys {
+		if i > 0 {
+			self.write(",")
+		}
+		self.newline(depth + 1)
+		self.write(quoteString(k) + colon)
+		if err := self.encode(dict[k], depth+1); err != nil {
+			return err
+		}
+	}
+	self.newline(depth)
+	self.write("}")
+
+	return nil
+}
+
+/*
+ * dictKeys returns the keys of a dict element in the order they should be
+ * written out. for JsonDictElement this is the insertion order, other
+ * implementations fall back to sorted order.
+ */
+func dictKeys(element JsonElement) []string {
+	if d, ok := element.(*JsonDictElement); ok {
+		return d.orderedKeys()
+	}
+
+	dict := element.ToDict()
+	keys := make([]string, 0, len(dict))
+
+	for k := range dict {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	return keys
+}
+
+func (self *EncodeOptions) Dump(w io.Writer, element JsonElement) error {
+	enc := newEncoder(w, self)
+
+	if err := enc.encode(element, 0); err != nil {
+		return err
+	}
+
+	return enc.w.Flush()
+}
+
+func (self *EncodeOptions) Dumps(element JsonElement) (string, error) {
+	var buf bytes.Buffer
+
+	if err := self.Dump(&buf, element); err != nil {
+		return "", err
+	}
+
+	return buf.String(), nil
+}
+
+func Dump(w io.Writer, element JsonElement) error {
+	return (*EncodeOptions)(nil).Dump(w, element)
+}
+
+func Dumps(element JsonElement) (string, error) {
+	return (*EncodeOptions)(nil).Dumps(element)
+}
+
+/*
+ * DumpsIndent is a shortcut for the common indented layout.
+ */
+func DumpsIndent(element JsonElement, prefix, indent string) (string, error) {
+	opts := &EncodeOptions{
+		Prefix: prefix,
+		Indent: indent,
+	}
+	return opts.Dumps(element)
+}
@@ -0,0 +1,278 @@
+package njson
+
+import (
+	"bytes"
+	"math"
+	"testing"
+)
+
+func TestDumpsStrings(t *testing.T) {
+	cases := []struct {
+		s    string
+		want string
+	}{
+		{``, `""`},
+		{`plain`, `"plain"`},
+		{`a"b\c/d`, `"a\"b\\c/d"`},
+		{"\n\r\t\b\f", `"\n\r\t\b\f"`},
+		{"\x00\x01\x1f\x7f", `"\u0000\u0001\u001f` + "\x7f" + `"`},
+		{"é 😀 <&>", `"é 😀 <&>"`},
+		{" ", "\" \""},
+		{"a\xffb", `"a\ufffdb"`},
+		{"\xed\xa0\x80", `"\ud800"`},
+	}
+
+	for _, c := range cases {
+		got, err := Dumps(NewJsonStringElement(c.s))
+		if err != nil || got != c.want {
+			t.Errorf("Dumps(%q) = %s, %v, want %s", c.s, got, err, c.want)
+		}
+	}
+}
+
+func TestDumpsNumbers(t *testing.T) {
+	cases := []struct {
+		element JsonElement
+		want    string
+	}{
+		{NewJsonIntegerElement(0), `0`},
+		{NewJsonIntegerElement(math.MinInt64), `-9223372036854775808`},
+		{NewJsonFloatElement(1), `1.0`},
+		{NewJsonFloatElement(-0.5), `-0.5`},
+		{NewJsonFloatElement(1e21), `1e+21`},
+		{NewJsonFloatElement(1e20), `100000000000000000000.0`},
+		{NewJsonFloatElement(1e-7), `1e-07`},
+		{NewJsonFloatElement(0.1), `0.1`},
+	}
+
+	for _, c := range cases {
+		got, err := Dumps(c.element)
+		if err != nil || got != c.want {
+			t.Errorf("Dumps(%v) = %s, %v, want %s", c.element, got, err, c.want)
+		}
+	}
+
+	for _, f := range []float64{math.NaN(), math.Inf(1), math.Inf(-1)} {
+		if _, err := Dumps(NewJsonArrayElement(NewJsonFloatElement(f))); err == nil {
+			t.Errorf("Dumps(%v) gave no error", f)
+		}
+	}
+}
+
+func TestDumpsKeyOrder(t *testing.T) {
+	obj, err := Loads(`{"b": 1, "a": 2, "c": {"z": 1, "y": 2}}`)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if s, _ := obj.Dumps(); s != `{"b":1,"a":2,"c":{"z":1,"y":2}}` {
+		t.Errorf("got %s", s)
+	}
+
+	// a new key goes at the end, a replaced one stays where it was
+	dict := obj.ToDictElement()
+	dict.Set("0", NewJsonNullElement())
+	dict.Set("b", NewJsonStringElement("x"))
+	dict.Delete("a")
+	if s, _ := obj.Dumps(); s != `{"b":"x","c":{"z":1,"y":2},"0":null}` {
+		t.Errorf("got %s", s)
+	}
+
+	var buf bytes.Buffer
+	if err := obj.Dump(&buf); err != nil || buf.String() != `{"b":"x","c":{"z":1,"y":2},"0":null}` {
+		t.Errorf("Dump: %s %v", buf.String(), err)
+	}
+}
+
+// a document written by Dumps reads back as the same document
+func TestDumpsRoundTrip(t *testing.T) {
+	docs := []string{
+		`{}`,
+		`[]`,
+		`{"a":[1,-2,3.5,1e+21,1.0,true,false,null],"b":{"c":{}}}`,
+		`{"s":"\"\\\/\b\f\n\r\tx\u0001é😀","":""}`,
+		`[[[]],[{}],{"k":[{"k":[1]}]}]`,
+		`"😀"`,
+		`-0.0`,
+	}
+
+	for _, doc := range docs {
+		element, err := LoadsValue(doc)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		for _, opts := range []*EncodeOptions{nil, {Indent: "  "}} {
+			s, err := opts.Dumps(element)
+			if err != nil {
+				t.Errorf("%s: %v", doc, err)
+				continue
+			}
+
+			again, err := LoadsValue(s)
+			if err != nil {
+				t.Errorf("%s written as %s: %v", doc, s, err)
+				continue
+			}
+			if !Equal(element, again) {
+				t.Errorf("%s written as %s reads back differently", doc, s)
+			}
+			if s2, _ := opts.Dumps(again); s2 != s {
+				t.Errorf("%s is written as %s then %s", doc, s, s2)
+			}
+		}
+	}
+}
+
+func TestEncodeOptions(t *testing.T) {
+	doc := `{"name": "svc", "ports": [8080, 8081, 8082, 8083, 8084, 8085, 8086, 8087, 8088], "b": {"z": 1, "a": [true, null]}, "tags": [], "deep": [{"k": "v"}, [1, 2]]}`
+
+	cases := []struct {
+		opts EncodeOptions
+		want string
+	}{
+		{EncodeOptions{SortKeys: true},
+			`{"b":{"a":[true,null],"z":1},"deep":[{"k":"v"},[1,2]],"name":"svc","ports":[8080,8081,8082,8083,8084,8085,8086,8087,8088],"tags":[]}`},
+
+		// MaxWidth and CompactArrays need indented output
+		{EncodeOptions{CompactArrays: true, MaxWidth: 10},
+			`{"name":"svc","ports":[8080,8081,8082,8083,8084,8085,8086,8087,8088],"b":{"z":1,"a":[true,null]},"tags":[],"deep":[{"k":"v"},[1,2]]}`},
+
+		{EncodeOptions{Indent: "  ", SortKeys: true}, `{
+  "b": {
+    "a": [
+      true,
+      null
+    ],
+    "z": 1
+  },
+  "deep": [
+    {
+      "k": "v"
+    },
+    [
+      1,
+      2
+    ]
+  ],
+  "name": "svc",
+  "ports": [
+    8080,
+    8081,
+    8082,
+    8083,
+    8084,
+    8085,
+    8086,
+    8087,
+    8088
+  ],
+  "tags": []
+}`},
+
+		{EncodeOptions{Indent: "  ", CompactArrays: true}, `{
+  "name": "svc",
+  "ports": [8080, 8081, 8082, 8083, 8084, 8085, 8086, 8087, 8088],
+  "b": {
+    "z": 1,
+    "a": [true, null]
+  },
+  "tags": [],
+  "deep": [
+    {
+      "k": "v"
+    },
+    [1, 2]
+  ]
+}`},
+
+		// long scalar arrays are wrapped, what fits in the line is joined
+		{EncodeOptions{Indent: "  ", CompactArrays: true, MaxWidth: 30}, `{
+  "name": "svc",
+  "ports": [
+    8080, 8081, 8082, 8083,
+    8084, 8085, 8086, 8087,
+    8088
+  ],
+  "b": {
+    "z": 1,
+    "a": [true, null]
+  },
+  "tags": [],
+  "deep": [{"k": "v"}, [1, 2]]
+}`},
+
+		{EncodeOptions{Indent: "  ", MaxWidth: 30}, `{
+  "name": "svc",
+  "ports": [
+    8080,
+    8081,
+    8082,
+    8083,
+    8084,
+    8085,
+    8086,
+    8087,
+    8088
+  ],
+  "b": {
+    "z": 1,
+    "a": [true, null]
+  },
+  "tags": [],
+  "deep": [{"k": "v"}, [1, 2]]
+}`},
+
+		{EncodeOptions{Indent: "  ", MaxWidth: 200, SortKeys: true},
+			`{"b": {"a": [true, null], "z": 1}, "deep": [{"k": "v"}, [1, 2]], "name": "svc", "ports": [8080, 8081, 8082, 8083, 8084, 8085, 8086, 8087, 8088], "tags": []}`},
+
+		{EncodeOptions{Prefix: "// ", Indent: "\t", MaxWidth: 40}, "{\n" +
+			"// \t\"name\": \"svc\",\n" +
+			"// \t\"ports\": [\n" +
+			"// \t\t8080,\n// \t\t8081,\n// \t\t8082,\n// \t\t8083,\n// \t\t8084,\n" +
+			"// \t\t8085,\n// \t\t8086,\n// \t\t8087,\n// \t\t8088\n" +
+			"// \t],\n" +
+			"// \t\"b\": {\"z\": 1, \"a\": [true, null]},\n" +
+			"// \t\"tags\": [],\n" +
+			"// \t\"deep\": [{\"k\": \"v\"}, [1, 2]]\n" +
+			"// }"},
+
+		{EncodeOptions{Prefix: "> "}, "{\n" +
+			"> \"name\": \"svc\",\n" +
+			"> \"ports\": [\n> 8080,\n> 8081,\n> 8082,\n> 8083,\n> 8084,\n> 8085,\n> 8086,\n> 8087,\n> 8088\n> ],\n" +
+			"> \"b\": {\n> \"z\": 1,\n> \"a\": [\n> true,\n> null\n> ]\n> },\n" +
+			"> \"tags\": [],\n" +
+			"> \"deep\": [\n> {\n> \"k\": \"v\"\n> },\n> [\n> 1,\n> 2\n> ]\n> ]\n" +
+			"> }"},
+	}
+
+	element, err := LoadsValue(doc)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, c := range cases {
+		opts := c.opts
+		got, err := opts.Dumps(element)
+		if err != nil {
+			t.Errorf("%+v: %v", c.opts, err)
+			continue
+		}
+		if got != c.want {
+			t.Errorf("%+v:\n%s\nwant\n%s", c.opts, got, c.want)
+		}
+
+		again, err := LoadsValue(got)
+		if err != nil && c.opts.Prefix == "" {
+			t.Errorf("%+v: %v", c.opts, err)
+		} else if err == nil && !Equal(again, element) {
+			t.Errorf("%+v: reads back differently", c.opts)
+		}
+	}
+
+	// a value that can not be written is an error on one line too
+	bad := NewJsonArrayElement(NewJsonFloatElement(math.NaN()))
+	if _, err := (&EncodeOptions{Indent: " ", MaxWidth: 80}).Dumps(bad); err == nil {
+		t.Errorf("NaN written")
+	}
+}
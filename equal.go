@@ -0,0 +1,225 @@
+package njson
+
+import (
+	"math"
+	"sort"
+	"strings"
+)
+
+func isNumber(element JsonElement) bool {
+	t := element.Type()
+	return t == ELE_INTEGER || t == ELE_FLOAT
+}
+
+// equalIntegerFloat compares an integer and a float exactly, without rounding the integer.
+func equalIntegerFloat(i int64, f float64) bool {
+	if f != math.Trunc(f) || f < math.MinInt64 || f >= math.MaxInt64 {
+		return false
+	}
+	return int64(f) == i
+}
+
+/*
+ * equalElements reports whether a and b hold the same json value, the order
+ * of dict keys is ignored. when numeric is set an integer and a float with
+ * the same value are equal.
+ */
+func equalElements(a, b JsonElement, numeric bool) bool {
+	ta, tb := a.Type(), b.Type()
+
+	if (ta == tb || numeric) && isNumber(a) && isNumber(b) &&
+		(lexemeOf(a) != "" || lexemeOf(b) != "") {
+		c, ok := compareNumbers(a, b)
+		return ok && c == 0
+	}
+
+	if ta != tb {
+		if !numeric || !isNumber(a) || !isNumber(b) {
+			return false
+		}
+
+		if ta == ELE_INTEGER {
+			return equalIntegerFloat(a.ToInteger64(), b.ToFloat64())
+		}
+		return equalIntegerFloat(b.ToInteger64(), a.ToFloat64())
+	}
+
+	switch ta {
+
+	case ELE_STRING:
+		return a.ToString() == b.ToString()
+	case ELE_INTEGER:
+		return a.ToInteger64() == b.ToInteger64()
+	case ELE_FLOAT:
+		return a.ToFloat64() == b.ToFloat64()
+	case ELE_BOOL:
+		return a.ToBool() == b.ToBool()
+	case ELE_NULL:
+		return true
+
+	case ELE_ARRAY:
+		ia, ib := a.ToElementArray(), b.ToElementArray()
+		if len(ia) != len(ib) {
+			return false
+		}
+		for i := range ia {
+			if !equalElements(ia[i], ib[i], numeric) {
+				return false
+			}
+		}
+		return true
+
+	case ELE_DICT:
+		da, db := a.ToDict(), b.ToDict()
+		if len(da) != len(db) {
+			return false
+		}
+		for k, va := range da {
+			vb, ok := db[k]
+			if !ok || !equalElements(va, vb, numeric) {
+				return false
+			}
+		}
+		return true
+	}
+
+	return false
+}
+
+/*
+ * EqualOptions controls Equal. the zero value (or a nil pointer) compares
+ * the types strictly, the integer 1 and the float 1.0 are different.
+ */
+type EqualOptions struct {
+	// Numeric makes an integer and a float of the same value equal.
+	Numeric bool
+}
+
+// Equal reports whether a and b hold the same json value, the order of dict keys, comments and spans are ignored.
+func (self *EqualOptions) Equal(a, b JsonElement) bool {
+	numeric := self != nil && self.Numeric
+	return equalElements(nullIfNil(a), nullIfNil(b), numeric)
+}
+
+func Equal(a, b JsonElement) bool {
+	return (*EqualOptions)(nil).Equal(a, b)
+}
+
+// typeRank gives the order of the types in Compare.
+func typeRank(element JsonElement) int {
+	switch element.Type() {
+	case ELE_NULL:
+		return 0
+	case ELE_BOOL:
+		return 1
+	case ELE_INTEGER, ELE_FLOAT:
+		return 2
+	case ELE_STRING:
+		return 3
+	case ELE_ARRAY:
+		return 4
+	case ELE_DICT:
+		return 5
+	}
+	return 6
+}
+
+func compareInts(a, b int) int {
+	switch {
+	case a < b:
+		return -1
+	case a > b:
+		return 1
+	}
+	return 0
+}
+
+func compareNumberElements(a, b JsonElement) int {
+	c, ok := compareNumbers(a, b)
+	if !ok {
+		// NaN comes before every other number
+		nanA := a.Type() == ELE_FLOAT && math.IsNaN(a.ToFloat64())
+		nanB := b.Type() == ELE_FLOAT && math.IsNaN(b.ToFloat64())
+		switch {
+		case nanA && nanB:
+			return 0
+		case nanA:
+			return -1
+		}
+		return 1
+	}
+
+	if c == 0 {
+		// 1 before 1.0, so only equal elements compare as 0
+		return compareInts(a.Type(), b.Type())
+	}
+	return c
+}
+
+/*
+ * Compare orders any two elements, it returns -1, 0 or +1. the types come in
+ * the order null, bool, number, string, array, dict. numbers are compared by
+ * value, an integer comes before a float of the same value. strings are
+ * compared byte by byte, arrays item by item. dicts are compared as their
+ * sorted lists of key / value pairs, so the order of their keys does not
+ * matter. Compare(a, b) == 0 when Equal(a, b).
+ */
+func Compare(a, b JsonElement) int {
+	a, b = nullIfNil(a), nullIfNil(b)
+
+	if c := compareInts(typeRank(a), typeRank(b)); c != 0 {
+		return c
+	}
+
+	switch a.Type() {
+
+	case ELE_BOOL:
+		x, y := 0, 0
+		if a.ToBool() {
+			x = 1
+		}
+		if b.ToBool() {
+			y = 1
+		}
+		return compareInts(x, y)
+
+	case ELE_INTEGER, ELE_FLOAT:
+		return compareNumberElements(a, b)
+
+	case ELE_STRING:
+		return strings.Compare(a.ToString(), b.ToString())
+
+	case ELE_ARRAY:
+		ia, ib := a.ToElementArray(), b.ToElementArray()
+		for i := 0; i < len(ia) && i < len(ib); i++ {
+			if c := Compare(ia[i], ib[i]); c != 0 {
+				return c
+			}
+		}
+		return compareInts(len(ia), len(ib))
+
+	case ELE_DICT:
+		da, db := a.ToDict(), b.ToDict()
+		ka, kb := sortedKeys(da), sortedKeys(db)
+		for i := 0; i < len(ka) && i < len(kb); i++ {
+			if c := strings.Compare(ka[i], kb[i]); c != 0 {
+				return c
+			}
+			if c := Compare(da[ka[i]], db[kb[i]]); c != 0 {
+				return c
+			}
+		}
+		return compareInts(len(ka), len(kb))
+	}
+
+	return 0
+}
+
+func sortedKeys(dict map[string]JsonElement) []string {
+	keys := make([]string, 0, len(dict))
+	for k := range dict {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
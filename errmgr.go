@@ -1,56 +1,208 @@
 package njson
 
 import (
-	"bufio"
+	"bytes"
 	"fmt"
-	"io"
 	"strings"
+	"unicode/utf8"
 )
 
-type NJsonError struct {
-	message  string
-	lno      int
-	offset   int
-	filepath string
-	source   []byte
-}
+// ErrorCode tells what kind of error a *NJsonError is, each code is an error usable with errors.Is.
+type ErrorCode int
 
-func (self *NJsonError) getLine() string {
-	lno := self.lno
+const (
+	// a token that does not fit the grammar, like a missing ':'
+	ErrUnexpectedToken ErrorCode = iota + 1
+	// the input ends inside a value, string or comment
+	ErrUnexpectedEOF
+	// a byte that starts no token
+	ErrInvalidCharacter
+	// a malformed escape sequence in a string
+	ErrInvalidEscape
+	// a control character, newline or lone surrogate in a string
+	ErrInvalidString
+	// a number that does not follow the number grammar
+	ErrInvalidNumber
+	// a number too large for int64 / float64
+	ErrNumberRange
+	// a key repeated in a dict, see DuplicateError
+	ErrDuplicateKey
+	// something after the root element
+	ErrTrailingContent
+	// the reader returned an error, it is unwrapped by errors.Unwrap
+	ErrRead
 
-	invalid := "(invalid line number)\n"
+	// limits of ParseOptions
+	ErrDepthLimit
+	ErrInputLimit
+	ErrStringLimit
+	ErrNumberLimit
+	ErrKeysLimit
+	ErrElementLimit
+)
 
-	if lno <= 0 {
-		return invalid
-	}
+var errorCodeNames = map[ErrorCode]string{
+	ErrUnexpectedToken:  "unexpected token",
+	ErrUnexpectedEOF:    "unexpected end of input",
+	ErrInvalidCharacter: "invalid character",
+	ErrInvalidEscape:    "invalid escape",
+	ErrInvalidString:    "invalid string",
+	ErrInvalidNumber:    "invalid number",
+	ErrNumberRange:      "number out of range",
+	ErrDuplicateKey:     "duplicate key",
+	ErrTrailingContent:  "trailing content",
+	ErrRead:             "read error",
+	ErrDepthLimit:       "nesting too deep",
+	ErrInputLimit:       "input too large",
+	ErrStringLimit:      "string too long",
+	ErrNumberLimit:      "number too long",
+	ErrKeysLimit:        "too many keys",
+	ErrElementLimit:     "too many elements",
+}
 
-	f := strings.NewReader(string(self.source))
+func (self ErrorCode) Error() string {
+	if name, ok := errorCodeNames[self]; ok {
+		return "njson: " + name
+	}
+	return fmt.Sprintf("njson: error %d", int(self))
+}
 
-	buf := bufio.NewReader(f)
+// codeError is an error message with its code, the tokenizer and parser turn it into a *NJsonError.
+type codeError struct {
+	code ErrorCode
+	msg  string
+}
 
-	for lc := 1; true; lc++ {
-		line, err := buf.ReadString('\n')
+func (self *codeError) Error() string {
+	return self.msg
+}
 
-		if err != nil && err != io.EOF {
-			break
-		}
+func errorf(code ErrorCode, format string, a ...interface{}) error {
+	return &codeError{code: code, msg: fmt.Sprintf(format, a...)}
+}
 
-		if lno == lc {
-			return fmt.Sprintf("file %s : %d : %d :\n   %s\n",
-				self.filepath, self.lno, self.offset, line)
-		}
+// codeOf returns the code of err, or def when it has none.
+func codeOf(err error, def ErrorCode) ErrorCode {
+	if ce, ok := err.(*codeError); ok {
+		return ce.code
 	}
+	return def
+}
+
+/*
+ * NJsonError is a malformed document. Line and Column (counted in bytes)
+ * start at 1, ByteOffset is the offset of the error in the input.
+ *
+ *	if errors.Is(err, njson.ErrDuplicateKey) { ... }
+ *
+ *	var e *njson.NJsonError
+ *	if errors.As(err, &e) {
+ *		fmt.Println(e.Render(2))
+ *	}
+ */
+type NJsonError struct {
+	File       string
+	Line       int
+	Column     int
+	ByteOffset int
+	Code       ErrorCode
+	Message    string
 
-	return invalid
+	source []byte // the whole input, nil for streams
+	cause  error  // the reader error of ErrRead
 }
 
 func (self *NJsonError) Error() string {
-	ln1 := self.getLine()
-	ln2 := "JsonFormatError : " + self.message
+	return fmt.Sprintf("%s:%d:%d: %s", self.File, self.Line, self.Column, self.Message)
+}
 
-	return ln1 + ln2
+// Is makes errors.Is(err, code) true for the code of the error.
+func (self *NJsonError) Is(target error) bool {
+	code, ok := target.(ErrorCode)
+	return ok && code == self.Code
+}
+
+func (self *NJsonError) Unwrap() error {
+	return self.cause
 }
 
 func (self *NJsonError) ThrowError() {
 	panic(self)
 }
+
+// lineAt returns the line starting at start without its line break, and where the next line starts.
+func lineAt(source []byte, start int) (string, int) {
+	end := bytes.IndexByte(source[start:], '\n')
+	if end < 0 {
+		return strings.TrimSuffix(string(source[start:]), "\r"), len(source)
+	}
+	return strings.TrimSuffix(string(source[start:start+end]), "\r"), start + end + 1
+}
+
+/*
+ * Render prints the error with the source lines around it and a caret under
+ * the column:
+ *
+ *	config.json:3:11: except ':'
+ *	   2 |   "name": "svc",
+ *	   3 |   "port" 8080
+ *	     |          ^
+ *	   4 | }
+ *
+ * context is the number of lines shown before and after the line of the
+ * error. only the first line is printed when the source was not kept.
+ */
+func (self *NJsonError) Render(context int) string {
+	var buf strings.Builder
+	buf.WriteString(self.Error())
+	buf.WriteByte('\n')
+
+	if self.source == nil || self.ByteOffset < 0 || self.ByteOffset > len(self.source) {
+		return buf.String()
+	}
+
+	// go back to the start of the line, then context lines more
+	starts := []int{}
+	pos := self.ByteOffset
+	for len(starts) <= context {
+		start := bytes.LastIndexByte(self.source[:pos], '\n') + 1
+		starts = append([]int{start}, starts...)
+		if start == 0 {
+			break
+		}
+		pos = start - 1
+	}
+
+	first := self.Line - len(starts) + 1
+	last := self.Line + context
+	width := len(fmt.Sprint(last))
+
+	pos = starts[0]
+	for lno := first; lno <= last && pos <= len(self.source); lno++ {
+		if pos == len(self.source) && lno > self.Line {
+			break
+		}
+
+		line, next := lineAt(self.source, pos)
+		fmt.Fprintf(&buf, "%*d | %s\n", width, lno, line)
+
+		if lno == self.Line {
+			// one space per character, tabs are kept so the caret lines up with the column
+			prefix := []byte{}
+			for i := 0; i < self.Column-1 && i < len(line); i++ {
+				switch {
+				case line[i] == '\t':
+					prefix = append(prefix, '\t')
+				case !utf8.RuneStart(line[i]):
+				default:
+					prefix = append(prefix, ' ')
+				}
+			}
+			fmt.Fprintf(&buf, "%*s | %s^\n", width, "", prefix)
+		}
+
+		pos = next
+	}
+
+	return buf.String()
+}
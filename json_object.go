@@ -1,5 +1,9 @@
 package njson
 
+import (
+	"io"
+)
+
 type JsonObject struct {
 	_dict *JsonDictElement
 }
@@ -30,3 +34,42 @@ func (self *JsonObject) ToDictElement() *JsonDictElement {
 func (self *JsonObject) ForEach(forfunc func(string, JsonElement)) {
 	self._dict.ForEach(forfunc)
 }
+
+func (self *JsonObject) Dumps() (string, error) {
+	return Dumps(self._dict)
+}
+
+func (self *JsonObject) Dump(w io.Writer) error {
+	return Dump(w, self._dict)
+}
+
+// Decode fills the value v points to, see Unmarshal.
+func (self *JsonObject) Decode(v interface{}) error {
+	return Unmarshal(self._dict, v)
+}
+
+// Query evaluates a JSONPath expression against the object, see JsonPath.
+func (self *JsonObject) Query(expr string) ([]JsonPathNode, error) {
+	return QueryJsonPath(self._dict, expr)
+}
+
+// ResolvePointer returns the element a JSON Pointer refers to, see Pointer.
+func (self *JsonObject) ResolvePointer(pointer string) (JsonElement, error) {
+	return ResolvePointer(self._dict, pointer)
+}
+
+// ApplyPatch applies a JSON Patch to the object, nothing is changed when an operation fails.
+func (self *JsonObject) ApplyPatch(patch Patch) error {
+	patched, err := patch.Apply(self._dict)
+	if err != nil {
+		return err
+	}
+
+	dict, ok := patched.(*JsonDictElement)
+	if !ok {
+		return &PatchError{Index: -1, Message: "the root of a JsonObject must stay a dict, got " + elementTypeName(patched)}
+	}
+
+	self._dict = dict
+	return nil
+}
@@ -1,4 +1,161 @@
 package njson
 
+import (
+	"errors"
+	"io"
+)
+
+const (
+	jsonBufferChunk    = 4096
+	jsonBufferMaxEmpty = 100
+)
+
+/*
+ * jsonBuffer is the input layer of the tokenizer. it reads the source in
+ * chunks and drops the bytes the tokenizer has consumed, so only the part
+ * of the input that is being tokenized stays in memory.
+ */
 type jsonBuffer struct {
+	reader io.Reader
+	data   []byte
+	pos    int  // index of the next unread byte in data
+	base   int  // offset of data[0] in the whole input
+	keep   bool // the whole input is in data, never drop anything
+	err    error
+	limit  int // bytes that may be read, 0 for no limit
+}
+
+// errInputLimit stops reading when the input is larger than the limit of the buffer.
+var errInputLimit = errors.New("input limit reached")
+
+func newJsonBuffer(r io.Reader) *jsonBuffer {
+	return &jsonBuffer{
+		reader: r,
+	}
+}
+
+func newJsonBufferFromBytes(b []byte) *jsonBuffer {
+	return &jsonBuffer{
+		data: b,
+		keep: true,
+		err:  io.EOF,
+	}
+}
+
+// fill makes sure at least n unread bytes are buffered, it returns false
+// when the input ends (or fails) before that.
+func (self *jsonBuffer) fill(n int) bool {
+	for len(self.data)-self.pos < n {
+		if self.err != nil {
+			return false
+		}
+
+		if !self.keep && self.pos > 0 {
+			rest := copy(self.data, self.data[self.pos:])
+			self.data = self.data[:rest]
+			self.base += self.pos
+			self.pos = 0
+		}
+
+		if cap(self.data)-len(self.data) < jsonBufferChunk {
+			grown := make([]byte, len(self.data), 2*cap(self.data)+jsonBufferChunk)
+			copy(grown, self.data)
+			self.data = grown
+		}
+
+		self.read()
+	}
+
+	return true
+}
+
+func (self *jsonBuffer) read() {
+	for i := 0; i < jsonBufferMaxEmpty; i++ {
+		n, err := self.reader.Read(self.data[len(self.data):cap(self.data)])
+		self.data = self.data[:len(self.data)+n]
+
+		if self.cut() {
+			return
+		}
+		if err != nil {
+			self.err = err
+			return
+		}
+		if n > 0 {
+			return
+		}
+	}
+
+	self.err = io.ErrNoProgress
+}
+
+// setLimit makes the buffer stop after n bytes, reading more is an errInputLimit.
+func (self *jsonBuffer) setLimit(n int) {
+	self.limit = n
+	self.cut()
+}
+
+// cut drops the bytes after the limit, it reports whether there were any.
+func (self *jsonBuffer) cut() bool {
+	if self.limit <= 0 || self.base+len(self.data) <= self.limit {
+		return false
+	}
+
+	self.data = self.data[:self.limit-self.base]
+	self.err = errInputLimit
+	return true
+}
+
+// atLimit reports whether the tokenizer is stopped by the input limit,
+// a few bytes of look ahead may still be left.
+func (self *jsonBuffer) atLimit() bool {
+	return self.err == errInputLimit && len(self.data)-self.pos < 16
+}
+
+func (self *jsonBuffer) peek(step int) (byte, bool) {
+	if !self.fill(step + 1) {
+		return 0, false
+	}
+	return self.data[self.pos+step], true
+}
+
+func (self *jsonBuffer) peekString(size int) string {
+	self.fill(size)
+
+	end := self.pos + size
+	if end > len(self.data) {
+		end = len(self.data)
+	}
+
+	return string(self.data[self.pos:end])
+}
+
+func (self *jsonBuffer) skip(n int) {
+	self.fill(n)
+
+	self.pos += n
+	if self.pos > len(self.data) {
+		self.pos = len(self.data)
+	}
+}
+
+// offset is the position of the next unread byte in the whole input.
+func (self *jsonBuffer) offset() int {
+	return self.base + self.pos
+}
+
+// Err returns the error that stopped reading, io.EOF is not an error.
+func (self *jsonBuffer) Err() error {
+	if self.err == io.EOF {
+		return nil
+	}
+	return self.err
+}
+
+// source returns the whole input, or nil when it is not kept in memory.
+func (self *jsonBuffer) source() []byte {
+	if self.keep {
+		return self.data
+	}
+	return nil
 }
@@ -0,0 +1,1112 @@
+package njson
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// kinds of values in filter expressions
+const (
+	_JP_VALUE = iota
+	_JP_LOGICAL
+	_JP_NODES
+)
+
+const jpathMaxInt = 1<<53 - 1
+
+type jpathNode struct {
+	path  string // normalized path, e.g. $['store']['book'][0]
+	value JsonElement
+}
+
+type jpathContext struct {
+	root    *jpathNode
+	regexps map[string]*regexp.Regexp
+}
+
+/*
+ * jpathValue is the result of a filter expression. a _JP_VALUE with a nil
+ * value is "Nothing", e.g. the result of a singular query that selects nothing.
+ */
+type jpathValue struct {
+	kind    int
+	value   JsonElement
+	logical bool
+	nodes   []*jpathNode
+}
+
+func (self jpathValue) truth() bool {
+	switch self.kind {
+	case _JP_LOGICAL:
+		return self.logical
+	case _JP_NODES:
+		return len(self.nodes) > 0
+	}
+	return self.value != nil
+}
+
+// toValue turns the nodes of a singular query into its value.
+func (self jpathValue) toValue() JsonElement {
+	if self.kind == _JP_NODES {
+		if len(self.nodes) == 1 {
+			return self.nodes[0].value
+		}
+		return nil
+	}
+	return self.value
+}
+
+func logicalValue(b bool) jpathValue {
+	return jpathValue{kind: _JP_LOGICAL, logical: b}
+}
+
+type jpathFunction struct {
+	params []int
+	result int
+	call   func(ctx *jpathContext, args []jpathValue) jpathValue
+}
+
+var jpathFunctions = map[string]*jpathFunction{
+	"length": {
+		params: []int{_JP_VALUE},
+		result: _JP_VALUE,
+		call:   jpathLength,
+	},
+	"count": {
+		params: []int{_JP_NODES},
+		result: _JP_VALUE,
+		call: func(ctx *jpathContext, args []jpathValue) jpathValue {
+			return jpathValue{kind: _JP_VALUE, value: NewJsonIntegerElement(int64(len(args[0].nodes)))}
+		},
+	},
+	"match": {
+		params: []int{_JP_VALUE, _JP_VALUE},
+		result: _JP_LOGICAL,
+		call: func(ctx *jpathContext, args []jpathValue) jpathValue {
+			return ctx.matchRegexp(args[0].value, args[1].value, true)
+		},
+	},
+	"search": {
+		params: []int{_JP_VALUE, _JP_VALUE},
+		result: _JP_LOGICAL,
+		call: func(ctx *jpathContext, args []jpathValue) jpathValue {
+			return ctx.matchRegexp(args[0].value, args[1].value, false)
+		},
+	},
+	"value": {
+		params: []int{_JP_NODES},
+		result: _JP_VALUE,
+		call: func(ctx *jpathContext, args []jpathValue) jpathValue {
+			return jpathValue{kind: _JP_VALUE, value: args[0].toValue()}
+		},
+	},
+}
+
+func jpathLength(ctx *jpathContext, args []jpathValue) jpathValue {
+	v := args[0].value
+	n := -1
+
+	if v != nil {
+		switch v.Type() {
+		case ELE_STRING:
+			n = utf8.RuneCountInString(v.ToString())
+		case ELE_ARRAY:
+			n = len(v.ToElementArray())
+		case ELE_DICT:
+			n = len(v.ToDict())
+		}
+	}
+
+	if n < 0 {
+		return jpathValue{kind: _JP_VALUE}
+	}
+	return jpathValue{kind: _JP_VALUE, value: NewJsonIntegerElement(int64(n))}
+}
+
+/*
+ * iregexpToGo translates an I-Regexp (RFC 9485) to go syntax,
+ * '.' outside of character classes must not match line breaks.
+ */
+func iregexpToGo(re string) string {
+	var buf strings.Builder
+	inClass := false
+
+	for i := 0; i < len(re); i++ {
+		ch := re[i]
+
+		switch {
+		case ch == '\\' && i+1 < len(re):
+			buf.WriteByte(ch)
+			buf.WriteByte(re[i+1])
+			i++
+			continue
+		case ch == '[':
+			inClass = true
+		case ch == ']':
+			inClass = false
+		case ch == '.' && !inClass:
+			buf.WriteString(`[^\n\r]`)
+			continue
+		}
+
+		buf.WriteByte(ch)
+	}
+
+	return buf.String()
+}
+
+func (self *jpathContext) matchRegexp(str JsonElement, pattern JsonElement, full bool) jpathValue {
+	if str == nil || pattern == nil || str.Type() != ELE_STRING || pattern.Type() != ELE_STRING {
+		return logicalValue(false)
+	}
+
+	re := iregexpToGo(pattern.ToString())
+	if full {
+		re = `\A(?:` + re + `)\z`
+	}
+
+	compiled, ok := self.regexps[re]
+	if !ok {
+		compiled, _ = regexp.Compile(re)
+		self.regexps[re] = compiled
+	}
+
+	if compiled == nil {
+		// an invalid regular expression matches nothing
+		return logicalValue(false)
+	}
+
+	return logicalValue(compiled.MatchString(str.ToString()))
+}
+
+// query evaluation
+
+func quoteNormalizedName(name string) string {
+	var buf strings.Builder
+	buf.WriteByte('\'')
+
+	for _, r := range name {
+		switch r {
+		case '\'':
+			buf.WriteString(`\'`)
+		case '\\':
+			buf.WriteString(`\\`)
+		case '\b':
+			buf.WriteString(`\b`)
+		case '\f':
+			buf.WriteString(`\f`)
+		case '\n':
+			buf.WriteString(`\n`)
+		case '\r':
+			buf.WriteString(`\r`)
+		case '\t':
+			buf.WriteString(`\t`)
+		default:
+			if r < 0x20 {
+				fmt.Fprintf(&buf, `\u%04x`, r)
+			} else {
+				buf.WriteRune(r)
+			}
+		}
+	}
+
+	buf.WriteByte('\'')
+	return buf.String()
+}
+
+func (self *jpathNode) member(key string, value JsonElement) *jpathNode {
+	return &jpathNode{
+		path:  self.path + "[" + quoteNormalizedName(key) + "]",
+		value: value,
+	}
+}
+
+func (self *jpathNode) item(index int, value JsonElement) *jpathNode {
+	return &jpathNode{
+		path:  self.path + "[" + strconv.Itoa(index) + "]",
+		value: value,
+	}
+}
+
+// children lists the members of a dict in key order or the items of an array.
+func (self *jpathNode) children() []*jpathNode {
+	v := self.value
+	out := []*jpathNode{}
+
+	switch v.Type() {
+	case ELE_DICT:
+		dict := v.ToDict()
+		for _, k := range dictKeys(v) {
+			out = append(out, self.member(k, dict[k]))
+		}
+	case ELE_ARRAY:
+		for i, item := range v.ToElementArray() {
+			out = append(out, self.item(i, item))
+		}
+	}
+
+	return out
+}
+
+func (self *jpathNode) descendants(out []*jpathNode) []*jpathNode {
+	out = append(out, self)
+	for _, child := range self.children() {
+		out = child.descendants(out)
+	}
+	return out
+}
+
+func (self *jpathQueryAST) nodes(ctx *jpathContext, current *jpathNode) []*jpathNode {
+	start := ctx.root
+	if self.relative {
+		start = current
+	}
+
+	nodes := []*jpathNode{start}
+	for _, seg := range self.segments {
+		nodes = seg.apply(ctx, nodes)
+	}
+
+	return nodes
+}
+
+func (self *jpathQueryAST) Execute(ctx *jpathContext, current *jpathNode) jpathValue {
+	return jpathValue{kind: _JP_NODES, nodes: self.nodes(ctx, current)}
+}
+
+func (self *jpathQueryAST) singular() bool {
+	for _, seg := range self.segments {
+		if seg.descendant || len(seg.selectors) != 1 {
+			return false
+		}
+
+		switch seg.selectors[0].(type) {
+		case *jpathNameAST, *jpathIndexAST:
+		default:
+			return false
+		}
+	}
+	return true
+}
+
+func (self *jpathSegmentAST) apply(ctx *jpathContext, nodes []*jpathNode) []*jpathNode {
+	out := []*jpathNode{}
+
+	for _, node := range nodes {
+		targets := []*jpathNode{node}
+		if self.descendant {
+			targets = node.descendants(nil)
+		}
+
+		for _, target := range targets {
+			for _, sel := range self.selectors {
+				out = sel.selectNodes(ctx, target, out)
+			}
+		}
+	}
+
+	return out
+}
+
+func (self *jpathNameAST) selectNodes(ctx *jpathContext, node *jpathNode, out []*jpathNode) []*jpathNode {
+	if node.value.Type() != ELE_DICT {
+		return out
+	}
+
+	if v, ok := node.value.ToDict()[self.name]; ok {
+		out = append(out, node.member(self.name, v))
+	}
+	return out
+}
+
+func (self *jpathWildcardAST) selectNodes(ctx *jpathContext, node *jpathNode, out []*jpathNode) []*jpathNode {
+	return append(out, node.children()...)
+}
+
+func (self *jpathIndexAST) selectNodes(ctx *jpathContext, node *jpathNode, out []*jpathNode) []*jpathNode {
+	if node.value.Type() != ELE_ARRAY {
+		return out
+	}
+
+	items := node.value.ToElementArray()
+	i := self.index
+	if i < 0 {
+		i += int64(len(items))
+	}
+
+	if i >= 0 && i < int64(len(items)) {
+		out = append(out, node.item(int(i), items[i]))
+	}
+	return out
+}
+
+func (self *jpathSliceAST) selectNodes(ctx *jpathContext, node *jpathNode, out []*jpathNode) []*jpathNode {
+	if node.value.Type() != ELE_ARRAY {
+		return out
+	}
+
+	items := node.value.ToElementArray()
+	n := int64(len(items))
+
+	step := int64(1)
+	if self.step != nil {
+		step = *self.step
+	}
+	if step == 0 {
+		return out
+	}
+
+	normalize := func(i int64) int64 {
+		if i < 0 {
+			return n + i
+		}
+		return i
+	}
+	clamp := func(i, lo, hi int64) int64 {
+		if i < lo {
+			return lo
+		}
+		if i > hi {
+			return hi
+		}
+		return i
+	}
+
+	if step > 0 {
+		start, end := int64(0), n
+		if self.start != nil {
+			start = normalize(*self.start)
+		}
+		if self.end != nil {
+			end = normalize(*self.end)
+		}
+
+		lower, upper := clamp(start, 0, n), clamp(end, 0, n)
+		for i := lower; i < upper; i += step {
+			out = append(out, node.item(int(i), items[i]))
+		}
+	} else {
+		start, end := n-1, -n-1
+		if self.start != nil {
+			start = normalize(*self.start)
+		}
+		if self.end != nil {
+			end = normalize(*self.end)
+		}
+
+		upper, lower := clamp(start, -1, n-1), clamp(end, -1, n-1)
+		for i := upper; lower < i; i += step {
+			out = append(out, node.item(int(i), items[i]))
+		}
+	}
+
+	return out
+}
+
+func (self *jpathFilterAST) selectNodes(ctx *jpathContext, node *jpathNode, out []*jpathNode) []*jpathNode {
+	for _, child := range node.children() {
+		if self.expr.Execute(ctx, child).truth() {
+			out = append(out, child)
+		}
+	}
+	return out
+}
+
+// filter expressions
+
+func (self *jpathOrAST) Execute(ctx *jpathContext, current *jpathNode) jpathValue {
+	for _, operand := range self.operands {
+		if operand.Execute(ctx, current).truth() {
+			return logicalValue(true)
+		}
+	}
+	return logicalValue(false)
+}
+
+func (self *jpathAndAST) Execute(ctx *jpathContext, current *jpathNode) jpathValue {
+	for _, operand := range self.operands {
+		if !operand.Execute(ctx, current).truth() {
+			return logicalValue(false)
+		}
+	}
+	return logicalValue(true)
+}
+
+func (self *jpathNotAST) Execute(ctx *jpathContext, current *jpathNode) jpathValue {
+	return logicalValue(!self.operand.Execute(ctx, current).truth())
+}
+
+func (self *jpathLiteralAST) Execute(ctx *jpathContext, current *jpathNode) jpathValue {
+	return jpathValue{kind: _JP_VALUE, value: self.value}
+}
+
+func (self *jpathFunctionAST) Execute(ctx *jpathContext, current *jpathNode) jpathValue {
+	args := make([]jpathValue, len(self.args))
+
+	for i, arg := range self.args {
+		v := arg.Execute(ctx, current)
+
+		switch self.fn.params[i] {
+		case _JP_VALUE:
+			v = jpathValue{kind: _JP_VALUE, value: v.toValue()}
+		case _JP_LOGICAL:
+			v = logicalValue(v.truth())
+		}
+		args[i] = v
+	}
+
+	return self.fn.call(ctx, args)
+}
+
+func jpathEqual(a, b JsonElement) bool {
+	if a == nil || b == nil {
+		return a == nil && b == nil
+	}
+	return equalElements(a, b, true)
+}
+
+func jpathLess(a, b JsonElement) bool {
+	if a == nil || b == nil {
+		return false
+	}
+
+	if isNumber(a) && isNumber(b) {
+		c, ok := compareNumbers(a, b)
+		return ok && c < 0
+	}
+
+	if a.Type() == ELE_STRING && b.Type() == ELE_STRING {
+		// utf-8 byte order is the order of the code points
+		return a.ToString() < b.ToString()
+	}
+
+	return false
+}
+
+func (self *jpathCompareAST) Execute(ctx *jpathContext, current *jpathNode) jpathValue {
+	a := self.left.Execute(ctx, current).toValue()
+	b := self.right.Execute(ctx, current).toValue()
+
+	switch self.op {
+	case "==":
+		return logicalValue(jpathEqual(a, b))
+	case "!=":
+		return logicalValue(!jpathEqual(a, b))
+	case "<":
+		return logicalValue(jpathLess(a, b))
+	case "<=":
+		return logicalValue(jpathLess(a, b) || jpathEqual(a, b))
+	case ">":
+		return logicalValue(jpathLess(b, a))
+	case ">=":
+		return logicalValue(jpathLess(b, a) || jpathEqual(a, b))
+	}
+
+	return logicalValue(false)
+}
+
+// parser, the tokenizer runs in jpath mode
+
+func jpathExprKind(expr jsonBaseAST) int {
+	switch e := expr.(type) {
+	case *jpathLiteralAST:
+		return _JP_VALUE
+	case *jpathQueryAST:
+		return _JP_NODES
+	case *jpathFunctionAST:
+		return e.fn.result
+	}
+	return _JP_LOGICAL
+}
+
+// checkTest makes sure expr can be used where a logical value is expected.
+func (self *parser) checkTest(expr jsonBaseAST) error {
+	if jpathExprKind(expr) == _JP_VALUE {
+		return self.syntaxError("except a logical expression, a query or a test function")
+	}
+	return nil
+}
+
+// checkComparable makes sure expr can be used as operand of a comparison.
+func (self *parser) checkComparable(expr jsonBaseAST) error {
+	if q, ok := expr.(*jpathQueryAST); ok {
+		if !q.singular() {
+			return self.syntaxError("only singular queries can be compared")
+		}
+		return nil
+	}
+
+	if jpathExprKind(expr) != _JP_VALUE {
+		return self.syntaxError("except a literal, a singular query or a value function")
+	}
+	return nil
+}
+
+func (self *parser) nowOp(ops ...string) bool {
+	tok := self.nowTok()
+	if tok.tokenType != _T_OP {
+		return false
+	}
+
+	for _, op := range ops {
+		if tok.value == op {
+			return true
+		}
+	}
+	return false
+}
+
+func (self *parser) parsePathInt() (int64, error) {
+	tok := self.nowTok()
+
+	if tok.tokenType != _T_INTEGER {
+		return 0, self.syntaxError("except integer")
+	}
+
+	s := strings.TrimPrefix(tok.value, "-")
+	if s == "" || (len(s) > 1 && s[0] == '0') || tok.value == "-0" {
+		return 0, self.syntaxError("invalid integer : " + tok.value)
+	}
+
+	v, err := strconv.ParseInt(tok.value, 10, 64)
+	if err != nil || v > jpathMaxInt || v < -jpathMaxInt {
+		return 0, self.syntaxError("integer out of range : " + tok.value)
+	}
+
+	self.nextTok()
+	return v, nil
+}
+
+func (self *parser) parseSliceOrIndex() (jsonSelectorAST, error) {
+	slice := &jpathSliceAST{}
+
+	if self.nowTok().tokenType == _T_INTEGER {
+		v, err := self.parsePathInt()
+		if err != nil {
+			return nil, err
+		}
+
+		if !self.nowTok().Equals(":") {
+			return &jpathIndexAST{index: v}, nil
+		}
+		slice.start = &v
+	}
+
+	self.nextTok() // eat ':'
+
+	if self.nowTok().tokenType == _T_INTEGER {
+		v, err := self.parsePathInt()
+		if err != nil {
+			return nil, err
+		}
+		slice.end = &v
+	}
+
+	if self.nowTok().Equals(":") {
+		self.nextTok() // eat ':'
+
+		if self.nowTok().tokenType == _T_INTEGER {
+			v, err := self.parsePathInt()
+			if err != nil {
+				return nil, err
+			}
+			slice.step = &v
+		}
+	}
+
+	return slice, nil
+}
+
+func (self *parser) parseSelector() (jsonSelectorAST, error) {
+	tok := self.nowTok()
+
+	switch tok.tokenType {
+
+	case _T_STRING:
+		self.nextTok()
+		return &jpathNameAST{name: tok.value}, nil
+
+	case _T_STAR:
+		self.nextTok()
+		return &jpathWildcardAST{}, nil
+
+	case _T_QUESTION:
+		self.nextTok() // eat '?'
+
+		expr, err := self.parseLogicalOr()
+		if err != nil {
+			return nil, err
+		}
+		if err := self.checkTest(expr); err != nil {
+			return nil, err
+		}
+
+		return &jpathFilterAST{expr: expr}, nil
+
+	case _T_INTEGER, _T_COLON:
+		return self.parseSliceOrIndex()
+	}
+
+	return nil, self.syntaxError("except selector")
+}
+
+func (self *parser) parseBracketedSelection() ([]jsonSelectorAST, error) {
+	self.nextTok() // eat '['
+
+	selectors := []jsonSelectorAST{}
+
+	for {
+		sel, err := self.parseSelector()
+		if err != nil {
+			return nil, err
+		}
+		selectors = append(selectors, sel)
+
+		if !self.nowTok().Equals(",") {
+			break
+		}
+		self.nextTok() // eat ','
+	}
+
+	if !self.nowTok().Equals("]") {
+		return nil, self.syntaxError("except ']'")
+	}
+	self.nextTok() // eat ']'
+
+	return selectors, nil
+}
+
+// parseShorthand parses the name or '*' after '.' and '..'
+func (self *parser) parseShorthand() (jsonSelectorAST, error) {
+	tok := self.nowTok()
+
+	switch tok.tokenType {
+	case _T_NAME:
+		self.nextTok()
+		return &jpathNameAST{name: tok.value}, nil
+	case _T_STAR:
+		self.nextTok()
+		return &jpathWildcardAST{}, nil
+	}
+
+	return nil, self.syntaxError("except member name or '*'")
+}
+
+func (self *parser) parsePathSegments(query *jpathQueryAST) error {
+	for {
+		seg := &jpathSegmentAST{}
+		tok := self.nowTok()
+
+		switch tok.tokenType {
+
+		case _T_DOT:
+			self.nextTok() // eat '.'
+
+			sel, err := self.parseShorthand()
+			if err != nil {
+				return err
+			}
+			seg.selectors = []jsonSelectorAST{sel}
+
+		case _T_DOTDOT:
+			self.nextTok() // eat '..'
+			seg.descendant = true
+
+			if self.nowTok().Equals("[") {
+				selectors, err := self.parseBracketedSelection()
+				if err != nil {
+					return err
+				}
+				seg.selectors = selectors
+			} else {
+				sel, err := self.parseShorthand()
+				if err != nil {
+					return err
+				}
+				seg.selectors = []jsonSelectorAST{sel}
+			}
+
+		case _T_MLBASKET:
+			selectors, err := self.parseBracketedSelection()
+			if err != nil {
+				return err
+			}
+			seg.selectors = selectors
+
+		default:
+			return nil
+		}
+
+		query.segments = append(query.segments, seg)
+	}
+}
+
+func (self *parser) parseFilterQuery() (*jpathQueryAST, error) {
+	query := &jpathQueryAST{
+		relative: self.nowTok().tokenType == _T_CURRENT,
+	}
+	self.nextTok() // eat '$' or '@'
+
+	if err := self.parsePathSegments(query); err != nil {
+		return nil, err
+	}
+	return query, nil
+}
+
+func (self *parser) parseFunction(name string) (jsonBaseAST, error) {
+	fn, ok := jpathFunctions[name]
+	if !ok {
+		return nil, self.syntaxError("unknown function : " + name)
+	}
+
+	self.nextTok() // eat '('
+
+	args := []jsonBaseAST{}
+
+	for self.nowTok().tokenType != _T_RPAREN {
+		if len(args) > 0 {
+			if !self.nowTok().Equals(",") {
+				return nil, self.syntaxError("except ',' or ')'")
+			}
+			self.nextTok() // eat ','
+		}
+
+		arg, err := self.parseLogicalOr()
+		if err != nil {
+			return nil, err
+		}
+
+		if len(args) >= len(fn.params) {
+			return nil, self.syntaxError(fmt.Sprintf("too many arguments for %s()", name))
+		}
+
+		switch fn.params[len(args)] {
+		case _JP_VALUE:
+			err = self.checkComparable(arg)
+		case _JP_LOGICAL:
+			err = self.checkTest(arg)
+		case _JP_NODES:
+			if _, ok := arg.(*jpathQueryAST); !ok {
+				err = self.syntaxError(fmt.Sprintf("%s() needs a query argument", name))
+			}
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		args = append(args, arg)
+	}
+
+	if len(args) != len(fn.params) {
+		return nil, self.syntaxError(fmt.Sprintf("%s() takes %d arguments", name, len(fn.params)))
+	}
+	self.nextTok() // eat ')'
+
+	return &jpathFunctionAST{
+		name: name,
+		fn:   fn,
+		args: args,
+	}, nil
+}
+
+// parseOperand parses a literal, a query or a function call.
+func (self *parser) parseOperand() (jsonBaseAST, error) {
+	tok := self.nowTok()
+
+	switch tok.tokenType {
+
+	case _T_ROOT, _T_CURRENT:
+		return self.parseFilterQuery()
+
+	case _T_STRING:
+		self.nextTok()
+		return &jpathLiteralAST{value: NewJsonStringElement(tok.value)}, nil
+
+	case _T_INTEGER:
+		v, err := self.parseInteger()
+		if err != nil {
+			return nil, err
+		}
+		return &jpathLiteralAST{value: v}, nil
+
+	case _T_FLOAT:
+		v, err := self.parseFloat()
+		if err != nil {
+			return nil, err
+		}
+		return &jpathLiteralAST{value: v}, nil
+
+	case _T_NAME:
+		self.nextTok()
+
+		if self.nowTok().tokenType == _T_LPAREN {
+			return self.parseFunction(tok.value)
+		}
+
+		switch tok.value {
+		case "true", "false":
+			return &jpathLiteralAST{value: NewJsonBoolElement(tok.value == "true")}, nil
+		case "null":
+			return &jpathLiteralAST{value: NewJsonNullElement()}, nil
+		}
+
+		return nil, self.syntaxError("except '(' after function name " + tok.value)
+	}
+
+	return nil, self.syntaxError("except literal, query or function")
+}
+
+func (self *parser) parseParenExpr() (jsonBaseAST, error) {
+	self.nextTok() // eat '('
+
+	expr, err := self.parseLogicalOr()
+	if err != nil {
+		return nil, err
+	}
+	if err := self.checkTest(expr); err != nil {
+		return nil, err
+	}
+
+	if self.nowTok().tokenType != _T_RPAREN {
+		return nil, self.syntaxError("except ')'")
+	}
+	self.nextTok() // eat ')'
+
+	return expr, nil
+}
+
+func (self *parser) parseBasicExpr() (jsonBaseAST, error) {
+	if self.nowOp("!") {
+		self.nextTok() // eat '!'
+
+		var operand jsonBaseAST
+		var err error
+
+		if self.nowTok().tokenType == _T_LPAREN {
+			operand, err = self.parseParenExpr()
+		} else {
+			operand, err = self.parseOperand()
+			if err == nil {
+				err = self.checkTest(operand)
+			}
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		return &jpathNotAST{operand: operand}, nil
+	}
+
+	if self.nowTok().tokenType == _T_LPAREN {
+		return self.parseParenExpr()
+	}
+
+	left, err := self.parseOperand()
+	if err != nil {
+		return nil, err
+	}
+
+	if !self.nowOp("==", "!=", "<", "<=", ">", ">=") {
+		// a test expression, or a function argument checked by the caller
+		return left, nil
+	}
+
+	op := self.nowTok().value
+	self.nextTok() // eat the operator
+
+	right, err := self.parseOperand()
+	if err != nil {
+		return nil, err
+	}
+
+	if err := self.checkComparable(left); err != nil {
+		return nil, err
+	}
+	if err := self.checkComparable(right); err != nil {
+		return nil, err
+	}
+
+	return &jpathCompareAST{
+		op:    op,
+		left:  left,
+		right: right,
+	}, nil
+}
+
+func (self *parser) parseLogicalAnd() (jsonBaseAST, error) {
+	first, err := self.parseBasicExpr()
+	if err != nil {
+		return nil, err
+	}
+
+	if !self.nowOp("&&") {
+		return first, nil
+	}
+
+	operands := []jsonBaseAST{first}
+
+	for self.nowOp("&&") {
+		self.nextTok() // eat '&&'
+
+		operand, err := self.parseBasicExpr()
+		if err != nil {
+			return nil, err
+		}
+		operands = append(operands, operand)
+	}
+
+	for _, operand := range operands {
+		if err := self.checkTest(operand); err != nil {
+			return nil, err
+		}
+	}
+
+	return &jpathAndAST{operands: operands}, nil
+}
+
+func (self *parser) parseLogicalOr() (jsonBaseAST, error) {
+	first, err := self.parseLogicalAnd()
+	if err != nil {
+		return nil, err
+	}
+
+	if !self.nowOp("||") {
+		return first, nil
+	}
+
+	operands := []jsonBaseAST{first}
+
+	for self.nowOp("||") {
+		self.nextTok() // eat '||'
+
+		operand, err := self.parseLogicalAnd()
+		if err != nil {
+			return nil, err
+		}
+		operands = append(operands, operand)
+	}
+
+	for _, operand := range operands {
+		if err := self.checkTest(operand); err != nil {
+			return nil, err
+		}
+	}
+
+	return &jpathOrAST{operands: operands}, nil
+}
+
+func (self *parser) parsePathQuery() (*jpathQueryAST, error) {
+	if self.nowTok().tokenType != _T_ROOT {
+		return nil, self.syntaxError("except '$'")
+	}
+
+	query, err := self.parseFilterQuery()
+	if err != nil {
+		return nil, err
+	}
+
+	if self.nowTok().tokenType != _T_EOF {
+		return nil, self.syntaxError("unexpected '" + self.nowTok().value + "'")
+	}
+
+	return query, nil
+}
+
+// public api
+
+/*
+ * JsonPath is a compiled JSONPath (RFC 9535) expression such as
+ * $.store.book[?@.price < 10].title, $..author or $.list[0:5:2]
+ */
+type JsonPath struct {
+	expr  string
+	query *jpathQueryAST
+}
+
+// JsonPathNode is a node selected by a JsonPath and its normalized path.
+type JsonPathNode struct {
+	Path  string
+	Value JsonElement
+}
+
+func CompileJsonPath(expr string) (*JsonPath, error) {
+	// number literals are kept exact, they may be compared with big numbers
+	lexer := newTokenizerFromBytes("<jsonpath>", []byte(expr), useNumberOptions)
+	lexer.jpathMode = true
+
+	query, err := newParser(lexer).parsePathQuery()
+	if err != nil {
+		return nil, err
+	}
+
+	return &JsonPath{
+		expr:  expr,
+		query: query,
+	}, nil
+}
+
+func DCompileJsonPath(expr string) *JsonPath {
+	path, err := CompileJsonPath(expr)
+
+	if err != nil {
+		panic(err)
+	}
+
+	return path
+}
+
+func (self *JsonPath) String() string {
+	return self.expr
+}
+
+// Query returns the nodes of element selected by the path, in document order.
+func (self *JsonPath) Query(element JsonElement) []JsonPathNode {
+	if element == nil {
+		return []JsonPathNode{}
+	}
+
+	root := &jpathNode{
+		path:  "$",
+		value: element,
+	}
+	ctx := &jpathContext{
+		root:    root,
+		regexps: map[string]*regexp.Regexp{},
+	}
+
+	nodes := self.query.nodes(ctx, root)
+	result := make([]JsonPathNode, len(nodes))
+
+	for i, node := range nodes {
+		result[i] = JsonPathNode{
+			Path:  node.path,
+			Value: node.value,
+		}
+	}
+
+	return result
+}
+
+// Values returns only the values of the nodes Query selects.
+func (self *JsonPath) Values(element JsonElement) []JsonElement {
+	nodes := self.Query(element)
+	values := make([]JsonElement, len(nodes))
+
+	for i, node := range nodes {
+		values[i] = node.Value
+	}
+
+	return values
+}
+
+func QueryJsonPath(element JsonElement, expr string) ([]JsonPathNode, error) {
+	path, err := CompileJsonPath(expr)
+	if err != nil {
+		return nil, err
+	}
+
+	return path.Query(element), nil
+}
@@ -0,0 +1,214 @@
+package njson
+
+import (
+	"strings"
+	"testing"
+)
+
+// the example documents and queries of RFC 9535, the results are normalized paths
+
+const rfcBookstore = `{ "store": {
+    "book": [
+      { "category": "reference",
+        "author": "Nigel Rees",
+        "title": "Sayings of the Century",
+        "price": 8.95
+      },
+      { "category": "fiction",
+        "author": "Evelyn Waugh",
+        "title": "Sword of Honour",
+        "price": 12.99
+      },
+      { "category": "fiction",
+        "author": "Herman Melville",
+        "title": "Moby Dick",
+        "isbn": "0-553-21311-3",
+        "price": 8.99
+      },
+      { "category": "fiction",
+        "author": "J. R. R. Tolkien",
+        "title": "The Lord of the Rings",
+        "isbn": "0-395-19395-8",
+        "price": 22.99
+      }
+    ],
+    "bicycle": {
+      "color": "red",
+      "price": 399
+    }
+  }
+}`
+
+var jsonPathCases = []struct {
+	doc   string
+	expr  string
+	paths []string
+}{
+	// 1.5, Table 2
+	{rfcBookstore, `$.store.book[*].author`, []string{
+		"$['store']['book'][0]['author']", "$['store']['book'][1]['author']",
+		"$['store']['book'][2]['author']", "$['store']['book'][3]['author']"}},
+	{rfcBookstore, `$..author`, []string{
+		"$['store']['book'][0]['author']", "$['store']['book'][1]['author']",
+		"$['store']['book'][2]['author']", "$['store']['book'][3]['author']"}},
+	{rfcBookstore, `$.store.*`, []string{"$['store']['book']", "$['store']['bicycle']"}},
+	{rfcBookstore, `$.store..price`, []string{
+		"$['store']['book'][0]['price']", "$['store']['book'][1]['price']",
+		"$['store']['book'][2]['price']", "$['store']['book'][3]['price']",
+		"$['store']['bicycle']['price']"}},
+	{rfcBookstore, `$..book[2]`, []string{"$['store']['book'][2]"}},
+	{rfcBookstore, `$..book[2].author`, []string{"$['store']['book'][2]['author']"}},
+	{rfcBookstore, `$..book[2].publisher`, []string{}},
+	{rfcBookstore, `$..book[-1]`, []string{"$['store']['book'][3]"}},
+	{rfcBookstore, `$..book[0,1]`, []string{"$['store']['book'][0]", "$['store']['book'][1]"}},
+	{rfcBookstore, `$..book[:2]`, []string{"$['store']['book'][0]", "$['store']['book'][1]"}},
+	{rfcBookstore, `$..book[?@.isbn]`, []string{"$['store']['book'][2]", "$['store']['book'][3]"}},
+	{rfcBookstore, `$..book[?@.price<10]`, []string{"$['store']['book'][0]", "$['store']['book'][2]"}},
+
+	// 2.2.3, root identifier
+	{`{"k": "v"}`, `$`, []string{"$"}},
+
+	// 2.3.1.3, name selector
+	{`{"o": {"j j": {"k.k": 3}}, "'": {"@": 2}}`, `$.o['j j']`, []string{"$['o']['j j']"}},
+	{`{"o": {"j j": {"k.k": 3}}, "'": {"@": 2}}`, `$.o['j j']['k.k']`, []string{"$['o']['j j']['k.k']"}},
+	{`{"o": {"j j": {"k.k": 3}}, "'": {"@": 2}}`, `$.o["j j"]["k.k"]`, []string{"$['o']['j j']['k.k']"}},
+	{`{"o": {"j j": {"k.k": 3}}, "'": {"@": 2}}`, `$["'"]["@"]`, []string{`$['\'']['@']`}},
+
+	// 2.3.2.3, wildcard selector
+	{`{"o": {"j": 1, "k": 2}, "a": [5, 3]}`, `$[*]`, []string{"$['o']", "$['a']"}},
+	{`{"o": {"j": 1, "k": 2}, "a": [5, 3]}`, `$.o[*]`, []string{"$['o']['j']", "$['o']['k']"}},
+	{`{"o": {"j": 1, "k": 2}, "a": [5, 3]}`, `$.o[*, *]`, []string{
+		"$['o']['j']", "$['o']['k']", "$['o']['j']", "$['o']['k']"}},
+	{`{"o": {"j": 1, "k": 2}, "a": [5, 3]}`, `$.a[*]`, []string{"$['a'][0]", "$['a'][1]"}},
+
+	// 2.3.3.3, index selector
+	{`["a", "b"]`, `$[1]`, []string{"$[1]"}},
+	{`["a", "b"]`, `$[-2]`, []string{"$[0]"}},
+
+	// 2.3.4.3, array slice selector
+	{`["a", "b", "c", "d", "e", "f", "g"]`, `$[1:3]`, []string{"$[1]", "$[2]"}},
+	{`["a", "b", "c", "d", "e", "f", "g"]`, `$[5:]`, []string{"$[5]", "$[6]"}},
+	{`["a", "b", "c", "d", "e", "f", "g"]`, `$[1:5:2]`, []string{"$[1]", "$[3]"}},
+	{`["a", "b", "c", "d", "e", "f", "g"]`, `$[5:1:-2]`, []string{"$[5]", "$[3]"}},
+	{`["a", "b", "c", "d", "e", "f", "g"]`, `$[::-1]`, []string{
+		"$[6]", "$[5]", "$[4]", "$[3]", "$[2]", "$[1]", "$[0]"}},
+
+	// 2.3.5.3, filter selector
+	{rfcFilterDoc, `$.a[?@.b == 'kilo']`, []string{"$['a'][9]"}},
+	{rfcFilterDoc, `$.a[?(@.b == 'kilo')]`, []string{"$['a'][9]"}},
+	{rfcFilterDoc, `$.a[?@>3.5]`, []string{"$['a'][1]", "$['a'][4]", "$['a'][5]"}},
+	{rfcFilterDoc, `$.a[?@.b]`, []string{"$['a'][6]", "$['a'][7]", "$['a'][8]", "$['a'][9]"}},
+	{rfcFilterDoc, `$[?@.*]`, []string{"$['a']", "$['o']"}},
+	{rfcFilterDoc, `$[?@[?@.b]]`, []string{"$['a']"}},
+	{rfcFilterDoc, `$.o[?@<3, ?@<3]`, []string{"$['o']['p']", "$['o']['q']", "$['o']['p']", "$['o']['q']"}},
+	{rfcFilterDoc, `$.a[?@<2 || @.b == "k"]`, []string{"$['a'][2]", "$['a'][7]"}},
+	{rfcFilterDoc, `$.a[?match(@.b, "[jk]")]`, []string{"$['a'][6]", "$['a'][7]"}},
+	{rfcFilterDoc, `$.a[?search(@.b, "[jk]")]`, []string{"$['a'][6]", "$['a'][7]", "$['a'][9]"}},
+	{rfcFilterDoc, `$.o[?@>1 && @<4]`, []string{"$['o']['q']", "$['o']['r']"}},
+	{rfcFilterDoc, `$.o[?@.u || @.x]`, []string{"$['o']['t']"}},
+	{rfcFilterDoc, `$.a[?@.b == $.x]`, []string{
+		"$['a'][0]", "$['a'][1]", "$['a'][2]", "$['a'][3]", "$['a'][4]", "$['a'][5]"}},
+	{rfcFilterDoc, `$.a[?@ == @]`, []string{
+		"$['a'][0]", "$['a'][1]", "$['a'][2]", "$['a'][3]", "$['a'][4]",
+		"$['a'][5]", "$['a'][6]", "$['a'][7]", "$['a'][8]", "$['a'][9]"}},
+
+	// 2.4, function extensions
+	{`[{"a": 1}, {"a": 1, "b": 2, "c": 3}, [1, 2, 3], "xy"]`, `$[?length(@) < 3]`, []string{"$[0]", "$[3]"}},
+	{`[{"a": 1}, {"a": 1, "b": 2}, []]`, `$[?count(@.*) == 1]`, []string{"$[0]"}},
+	{`[{"timezone": "Europe/Paris"}, {"timezone": "America/Lima"}]`, `$[?match(@.timezone, 'Europe/.*')]`, []string{"$[0]"}},
+	{`[{"color": "red"}, {"b": {"color": "red"}}, {"a": {"color": "red"}, "b": {"color": "red"}}]`,
+		`$[?value(@..color) == "red"]`, []string{"$[0]", "$[1]"}},
+
+	// 2.5.1.3, child segment
+	{`["a", "b", "c", "d", "e", "f", "g"]`, `$[0, 3]`, []string{"$[0]", "$[3]"}},
+	{`["a", "b", "c", "d", "e", "f", "g"]`, `$[0:2, 5]`, []string{"$[0]", "$[1]", "$[5]"}},
+	{`["a", "b", "c", "d", "e", "f", "g"]`, `$[0, 0]`, []string{"$[0]", "$[0]"}},
+
+	// 2.5.2.3, descendant segment
+	{rfcDescendantDoc, `$..j`, []string{"$['o']['j']", "$['a'][2][0]['j']"}},
+	{rfcDescendantDoc, `$..[0]`, []string{"$['a'][0]", "$['a'][2][0]"}},
+	{rfcDescendantDoc, `$..o`, []string{"$['o']"}},
+	{rfcDescendantDoc, `$.o..[*, *]`, []string{"$['o']['j']", "$['o']['k']", "$['o']['j']", "$['o']['k']"}},
+	{rfcDescendantDoc, `$.a..[0, 1]`, []string{"$['a'][0]", "$['a'][1]", "$['a'][2][0]", "$['a'][2][1]"}},
+
+	// 2.6.1, semantics of null
+	{rfcNullDoc, `$.a`, []string{"$['a']"}},
+	{rfcNullDoc, `$.a[0]`, []string{}},
+	{rfcNullDoc, `$.a.d`, []string{}},
+	{rfcNullDoc, `$.b[0]`, []string{"$['b'][0]"}},
+	{rfcNullDoc, `$.b[*]`, []string{"$['b'][0]"}},
+	{rfcNullDoc, `$.b[?@]`, []string{"$['b'][0]"}},
+	{rfcNullDoc, `$.b[?@==null]`, []string{"$['b'][0]"}},
+	{rfcNullDoc, `$.c[?@.d==null]`, []string{}},
+	{rfcNullDoc, `$.null`, []string{"$['null']"}},
+
+	// blank space is allowed before a segment, not inside one
+	{`{"a": {"b": 1}}`, "$ .a\t.b", []string{"$['a']['b']"}},
+	{`{"a": {"b": 1}}`, "$ ..b", []string{"$['a']['b']"}},
+}
+
+const rfcFilterDoc = `{
+  "a": [3, 5, 1, 2, 4, 6,
+        {"b": "j"},
+        {"b": "k"},
+        {"b": {}},
+        {"b": "kilo"}
+       ],
+  "o": {"p": 1, "q": 2, "r": 3, "s": 5, "t": {"u": 6}},
+  "e": "f"
+}`
+
+const rfcDescendantDoc = `{
+  "o": {"j": 1, "k": 2},
+  "a": [5, 3, [{"j": 4}, {"k": 6}]]
+}`
+
+const rfcNullDoc = `{"a": null, "b": [null], "c": [{}], "null": 1}`
+
+func TestJsonPathRFCExamples(t *testing.T) {
+	for _, c := range jsonPathCases {
+		doc, err := LoadsValue(c.doc)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		nodes, err := QueryJsonPath(doc, c.expr)
+		if err != nil {
+			t.Errorf("%s: %v", c.expr, err)
+			continue
+		}
+
+		got := []string{}
+		for _, n := range nodes {
+			got = append(got, n.Path)
+		}
+		if strings.Join(got, " ") != strings.Join(c.paths, " ") {
+			t.Errorf("%s = %v, want %v", c.expr, got, c.paths)
+		}
+	}
+}
+
+func TestJsonPathInvalid(t *testing.T) {
+	exprs := []string{
+		// blank space after '.' and '..'
+		`$. a`,
+		`$ . a`,
+		"$.\ta",
+		`$.. a`,
+		`$.. [0]`,
+		`$..	*`,
+		`$.a. b`,
+
+		// 2.4, not well-typed function expressions
+		`$[?length(@.*) < 3]`,
+		`$[?count(1) == 1]`,
+		`$[?match(@.timezone, 'Europe/.*') == true]`,
+		`$[?value(@..color)]`,
+	}
+
+	for _, expr := range exprs {
+		if _, err := CompileJsonPath(expr); err == nil {
+			t.Errorf("%q compiled", expr)
+		}
+	}
+}
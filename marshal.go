@@ -0,0 +1,308 @@
+package njson
+
+import (
+	"math"
+	"reflect"
+	"sort"
+	"strconv"
+)
+
+type visitKey struct {
+	ptr uintptr
+	len int
+	typ reflect.Type
+}
+
+type marshaler struct {
+	visiting map[visitKey]bool
+}
+
+// enter marks a pointer, map or slice as being marshaled, a value that
+// is entered twice refers to itself.
+func (self *marshaler) enter(v reflect.Value, path string) (visitKey, error) {
+	key := visitKey{
+		ptr: v.Pointer(),
+		typ: v.Type(),
+	}
+	if v.Kind() == reflect.Slice {
+		key.len = v.Len()
+	}
+
+	if self.visiting[key] {
+		return key, pathError(path, "cycle detected in %s", v.Type())
+	}
+	self.visiting[key] = true
+
+	return key, nil
+}
+
+func (self *marshaler) leave(key visitKey) {
+	delete(self.visiting, key)
+}
+
+func (self *marshaler) marshal(v reflect.Value, path string) (JsonElement, error) {
+	if !v.IsValid() {
+		return NewJsonNullElement(), nil
+	}
+
+	if v.Type().Implements(elementTypeType) {
+		if (v.Kind() == reflect.Ptr || v.Kind() == reflect.Interface) && v.IsNil() {
+			return NewJsonNullElement(), nil
+		}
+		return v.Interface().(JsonElement), nil
+	}
+
+	if ele, ok := objectElement(v); ok {
+		return ele, nil
+	}
+
+	if s, ok := numberText(v); ok {
+		return marshalNumber(s, path)
+	}
+
+	switch v.Kind() {
+
+	case reflect.Bool:
+		return NewJsonBoolElement(v.Bool()), nil
+
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return NewJsonIntegerElement(v.Int()), nil
+
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		n := v.Uint()
+		if n > math.MaxInt64 {
+			return marshalNumber(strconv.FormatUint(n, 10), path)
+		}
+		return NewJsonIntegerElement(int64(n)), nil
+
+	case reflect.Float32, reflect.Float64:
+		f := v.Float()
+		if math.IsNaN(f) || math.IsInf(f, 0) {
+			return nil, pathError(path, "unsupported float value : %v", f)
+		}
+		if v.Kind() == reflect.Float32 {
+			// keep the short form of the float32 value
+			f, _ = strconv.ParseFloat(strconv.FormatFloat(f, 'g', -1, 32), 64)
+		}
+		return NewJsonFloatElement(f), nil
+
+	case reflect.String:
+		return NewJsonStringElement(v.String()), nil
+
+	case reflect.Interface:
+		if v.IsNil() {
+			return NewJsonNullElement(), nil
+		}
+		return self.marshal(v.Elem(), path)
+
+	case reflect.Ptr:
+		if v.IsNil() {
+			return NewJsonNullElement(), nil
+		}
+
+		key, err := self.enter(v, path)
+		if err != nil {
+			return nil, err
+		}
+		defer self.leave(key)
+
+		return self.marshal(v.Elem(), path)
+
+	case reflect.Slice:
+		if v.IsNil() {
+			return NewJsonNullElement(), nil
+		}
+
+		key, err := self.enter(v, path)
+		if err != nil {
+			return nil, err
+		}
+		defer self.leave(key)
+
+		return self.marshalArray(v, path)
+
+	case reflect.Array:
+		return self.marshalArray(v, path)
+
+	case reflect.Map:
+		if v.IsNil() {
+			return NewJsonNullElement(), nil
+		}
+
+		key, err := self.enter(v, path)
+		if err != nil {
+			return nil, err
+		}
+		defer self.leave(key)
+
+		return self.marshalMap(v, path)
+
+	case reflect.Struct:
+		return self.marshalStruct(v, path)
+	}
+
+	return nil, pathError(path, "unsupported type %s", v.Type())
+}
+
+var jsonObjectType = reflect.TypeOf(JsonObject{})
+
+// objectElement returns the dict of a JsonObject or *JsonObject, its only field is unexported.
+func objectElement(v reflect.Value) (JsonElement, bool) {
+	var obj *JsonObject
+
+	switch {
+	case v.Type() == jsonObjectType && v.CanInterface():
+		o := v.Interface().(JsonObject)
+		obj = &o
+	case v.Type() == reflect.PtrTo(jsonObjectType) && v.CanInterface():
+		obj = v.Interface().(*JsonObject)
+	default:
+		return nil, false
+	}
+
+	if obj == nil || obj._dict == nil {
+		return NewJsonNullElement(), true
+	}
+	return obj._dict, true
+}
+
+func marshalNumber(s string, path string) (JsonElement, error) {
+	ele, err := NewJsonNumberElement(s)
+	if err != nil {
+		return nil, pathError(path, "invalid number %q", s)
+	}
+	return ele, nil
+}
+
+func (self *marshaler) marshalArray(v reflect.Value, path string) (JsonElement, error) {
+	array := NewJsonArrayElement()
+
+	for i := 0; i < v.Len(); i++ {
+		item, err := self.marshal(v.Index(i), path+"["+strconv.Itoa(i)+"]")
+		if err != nil {
+			return nil, err
+		}
+		array.add(item)
+	}
+
+	return array, nil
+}
+
+func (self *marshaler) marshalMap(v reflect.Value, path string) (JsonElement, error) {
+	type entry struct {
+		key   string
+		value reflect.Value
+	}
+
+	entries := []entry{}
+	iter := v.MapRange()
+
+	for iter.Next() {
+		k := iter.Key()
+		var key string
+
+		switch k.Kind() {
+		case reflect.String:
+			key = k.String()
+		case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+			key = strconv.FormatInt(k.Int(), 10)
+		case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+			key = strconv.FormatUint(k.Uint(), 10)
+		default:
+			return nil, pathError(path, "unsupported map key type %s", k.Type())
+		}
+
+		entries = append(entries, entry{key, iter.Value()})
+	}
+
+	// go maps have no order, sort the keys to get the same document every time
+	sort.Slice(entries, func(i, j int) bool {
+		return entries[i].key < entries[j].key
+	})
+
+	dict := NewJsonDictElement()
+
+	for _, e := range entries {
+		value, err := self.marshal(e.value, joinPath(path, e.key))
+		if err != nil {
+			return nil, err
+		}
+		dict.set(e.key, value)
+	}
+
+	return dict, nil
+}
+
+func isEmptyValue(v reflect.Value) bool {
+	switch v.Kind() {
+
+	case reflect.Array, reflect.Map, reflect.Slice, reflect.String:
+		return v.Len() == 0
+	case reflect.Bool:
+		return !v.Bool()
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return v.Int() == 0
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return v.Uint() == 0
+	case reflect.Float32, reflect.Float64:
+		return v.Float() == 0
+	case reflect.Interface, reflect.Ptr:
+		return v.IsNil()
+	}
+
+	return false
+}
+
+// structFieldValue returns the field at index, ok is false when an embedded struct pointer on the way is nil.
+func structFieldValue(v reflect.Value, index []int) (reflect.Value, bool) {
+	for i, x := range index {
+		if i > 0 && v.Kind() == reflect.Ptr {
+			if v.IsNil() {
+				return reflect.Value{}, false
+			}
+			v = v.Elem()
+		}
+		v = v.Field(x)
+	}
+	return v, true
+}
+
+func (self *marshaler) marshalStruct(v reflect.Value, path string) (JsonElement, error) {
+	dict := NewJsonDictElement()
+
+	for _, field := range structFields(v.Type()) {
+		// the fields of a nil embedded *T are left out, like encoding/json does
+		fv, ok := structFieldValue(v, field.index)
+		if !ok {
+			continue
+		}
+
+		if field.omitEmpty && isEmptyValue(fv) {
+			continue
+		}
+
+		value, err := self.marshal(fv, joinPath(path, field.name))
+		if err != nil {
+			return nil, err
+		}
+		dict.set(field.name, value)
+	}
+
+	return dict, nil
+}
+
+/*
+ * Marshal builds an element tree from a go value. structs become dicts
+ * (the field name comes from the `njson:"name,omitempty"` tag), maps with
+ * string or integer keys become dicts with sorted keys, slices and arrays
+ * become arrays, nil pointers, slices and maps become null. a JsonObject
+ * is written as its dict. a value that refers to itself is reported as an
+ * error.
+ */
+func Marshal(value interface{}) (JsonElement, error) {
+	m := &marshaler{
+		visiting: map[visitKey]bool{},
+	}
+
+	return m.marshal(reflect.ValueOf(value), "")
+}
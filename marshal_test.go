@@ -0,0 +1,37 @@
+package njson
+
+import (
+	"testing"
+)
+
+func TestMarshalJsonObject(t *testing.T) {
+	obj, err := Loads(`{"a": [1, {"b": 2}]}`)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cases := []struct {
+		value interface{}
+		want  string
+	}{
+		{obj, `{"a":[1,{"b":2}]}`},
+		{*obj, `{"a":[1,{"b":2}]}`},
+		{(*JsonObject)(nil), `null`},
+		{struct {
+			Doc  *JsonObject `njson:"doc"`
+			None *JsonObject `njson:"none"`
+		}{Doc: obj}, `{"doc":{"a":[1,{"b":2}]},"none":null}`},
+		{map[string]JsonObject{"x": *obj}, `{"x":{"a":[1,{"b":2}]}}`},
+	}
+
+	for _, c := range cases {
+		element, err := Marshal(c.value)
+		if err != nil {
+			t.Errorf("Marshal(%T): %v", c.value, err)
+			continue
+		}
+		if s, _ := Dumps(element); s != c.want {
+			t.Errorf("Marshal(%T) = %s, want %s", c.value, s, c.want)
+		}
+	}
+}
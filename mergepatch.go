@@ -0,0 +1,116 @@
+package njson
+
+import (
+	"fmt"
+)
+
+/*
+ * MergePatch applies a JSON Merge Patch (RFC 7386) to target and returns
+ * the result: a null member of the patch deletes the key, a dict is merged
+ * into the dict of the same key, any other value replaces it. the keys of
+ * target keep their order and new keys are added at the end. target and
+ * patch are left unchanged.
+ */
+func MergePatch(target, patch JsonElement) JsonElement {
+	patch = nullIfNil(patch)
+
+	if patch.Type() != ELE_DICT {
+		return cloneElement(patch)
+	}
+
+	var result *JsonDictElement
+	if target != nil && target.Type() == ELE_DICT {
+		result = cloneElement(target).(*JsonDictElement)
+	} else {
+		result = NewJsonDictElement()
+	}
+
+	members := patch.ToDict()
+	for _, k := range dictKeys(patch) {
+		v := members[k]
+
+		if v.Type() == ELE_NULL {
+			result.Delete(k)
+			continue
+		}
+		result.set(k, MergePatch(result.dict[k], v))
+	}
+
+	return result
+}
+
+// nullMember returns the path of the first null dict member of element, a merge patch would delete it.
+func nullMember(path Pointer, element JsonElement) (Pointer, bool) {
+	if element.Type() != ELE_DICT {
+		return nil, false
+	}
+
+	members := element.ToDict()
+	for _, k := range dictKeys(element) {
+		if members[k].Type() == ELE_NULL {
+			return path.Child(k), true
+		}
+		if p, ok := nullMember(path.Child(k), members[k]); ok {
+			return p, true
+		}
+	}
+	return nil, false
+}
+
+func nullMemberError(path Pointer) error {
+	return fmt.Errorf("the null value at %q can not be written in a merge patch", path.String())
+}
+
+func createMergePatch(path Pointer, original, modified JsonElement) (JsonElement, error) {
+	if original.Type() != ELE_DICT || modified.Type() != ELE_DICT {
+		if p, ok := nullMember(path, modified); ok {
+			return nil, nullMemberError(p)
+		}
+		return cloneElement(modified), nil
+	}
+
+	patch := NewJsonDictElement()
+	om, mm := original.ToDict(), modified.ToDict()
+
+	for _, k := range dictKeys(original) {
+		v, ok := mm[k]
+		switch {
+		case !ok:
+			patch.set(k, NewJsonNullElement())
+		case equalElements(om[k], v, false):
+		case v.Type() == ELE_NULL:
+			return nil, nullMemberError(path.Child(k))
+		default:
+			sub, err := createMergePatch(path.Child(k), om[k], v)
+			if err != nil {
+				return nil, err
+			}
+			patch.set(k, sub)
+		}
+	}
+
+	for _, k := range dictKeys(modified) {
+		if _, ok := om[k]; ok {
+			continue
+		}
+		if mm[k].Type() == ELE_NULL {
+			return nil, nullMemberError(path.Child(k))
+		}
+		sub, err := createMergePatch(path.Child(k), NewJsonNullElement(), mm[k])
+		if err != nil {
+			return nil, err
+		}
+		patch.set(k, sub)
+	}
+
+	return patch, nil
+}
+
+/*
+ * CreateMergePatch returns the merge patch that turns original into
+ * modified. it fails when a dict member becomes null in modified, a merge
+ * patch can only delete such a key.
+ */
+func CreateMergePatch(original, modified JsonElement) (JsonElement, error) {
+	return createMergePatch(Pointer{}, nullIfNil(original), nullIfNil(modified))
+}
@@ -0,0 +1,102 @@
+package njson
+
+import (
+	"testing"
+)
+
+func TestMergePatch(t *testing.T) {
+	cases := []struct {
+		target, patch, want string
+	}{
+		// RFC 7386 Appendix A
+		{`{"a":"b"}`, `{"a":"c"}`, `{"a":"c"}`},
+		{`{"a":"b"}`, `{"b":"c"}`, `{"a":"b","b":"c"}`},
+		{`{"a":"b"}`, `{"a":null}`, `{}`},
+		{`{"a":"b","b":"c"}`, `{"a":null}`, `{"b":"c"}`},
+		{`{"a":["b"]}`, `{"a":"c"}`, `{"a":"c"}`},
+		{`{"a":"c"}`, `{"a":["b"]}`, `{"a":["b"]}`},
+		{`{"a":{"b":"c"}}`, `{"a":{"b":"d","c":null}}`, `{"a":{"b":"d"}}`},
+		{`{"a":[{"b":"c"}]}`, `{"a":[1]}`, `{"a":[1]}`},
+		{`["a","b"]`, `["c","d"]`, `["c","d"]`},
+		{`{"a":"b"}`, `["c"]`, `["c"]`},
+		{`{"a":"foo"}`, `null`, `null`},
+		{`{"a":"foo"}`, `"bar"`, `"bar"`},
+		{`{"e":null}`, `{"a":1}`, `{"e":null,"a":1}`},
+		{`[1,2]`, `{"a":"b","c":null}`, `{"a":"b"}`},
+		{`{}`, `{"a":{"bb":{"ccc":null}}}`, `{"a":{"bb":{}}}`},
+
+		// the keys of the target keep their place, new keys are added at the end
+		{`{"z":1,"a":2,"m":3}`, `{"n":4,"a":5,"b":6}`, `{"z":1,"a":5,"m":3,"n":4,"b":6}`},
+		{`{"z":1,"a":2,"m":3}`, `{"a":null,"a2":7}`, `{"z":1,"m":3,"a2":7}`},
+		{`{"z":{"y":1,"x":2}}`, `{"z":{"w":3,"y":4}}`, `{"z":{"y":4,"x":2,"w":3}}`},
+	}
+
+	for _, c := range cases {
+		target, err := LoadsValue(c.target)
+		if err != nil {
+			t.Fatal(err)
+		}
+		patch, err := LoadsValue(c.patch)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if s, _ := Dumps(MergePatch(target, patch)); s != c.want {
+			t.Errorf("MergePatch(%s, %s) = %s, want %s", c.target, c.patch, s, c.want)
+		}
+		if s, _ := Dumps(target); s != c.target {
+			t.Errorf("MergePatch changed its target: %s", s)
+		}
+	}
+}
+
+func TestCreateMergePatch(t *testing.T) {
+	cases := []struct {
+		original, modified, want string
+	}{
+		{`{"a":"b","c":{"d":"e","f":"g"}}`, `{"a":"z","c":{"d":"e"}}`, `{"a":"z","c":{"f":null}}`},
+		{`{"a":1}`, `{"a":1}`, `{}`},
+		{`{"a":1,"b":2}`, `{"b":2,"c":{"d":3}}`, `{"a":null,"c":{"d":3}}`},
+		{`{"a":[1,2]}`, `{"a":[1]}`, `{"a":[1]}`},
+		{`[1]`, `{"a":1}`, `{"a":1}`},
+		{`{"a":1}`, `"x"`, `"x"`},
+
+		// a member that becomes null can not be written, the patch would delete it
+		{`{"a":1}`, `{"a":null}`, ``},
+		{`{}`, `{"a":null}`, ``},
+		{`{"a":{"b":1}}`, `{"a":{"b":null}}`, ``},
+		{`{}`, `{"a":{"b":null}}`, ``},
+		{`{"a":1}`, `{"a":{"b":[{"c":null}]}}`, `{"a":{"b":[{"c":null}]}}`},
+		{`{"a":1}`, `{"a":{"b":{"c":null}}}`, ``},
+	}
+
+	for _, c := range cases {
+		original, err := LoadsValue(c.original)
+		if err != nil {
+			t.Fatal(err)
+		}
+		modified, err := LoadsValue(c.modified)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		patch, err := CreateMergePatch(original, modified)
+		if c.want == "" {
+			if err == nil {
+				s, _ := Dumps(patch)
+				t.Errorf("CreateMergePatch(%s, %s) = %s, want an error", c.original, c.modified, s)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("CreateMergePatch(%s, %s): %v", c.original, c.modified, err)
+			continue
+		}
+		if s, _ := Dumps(patch); s != c.want {
+			t.Errorf("CreateMergePatch(%s, %s) = %s, want %s", c.original, c.modified, s, c.want)
+		}
+		if !Equal(MergePatch(original, patch), modified) {
+			t.Errorf("CreateMergePatch(%s, %s) does not give back modified", c.original, c.modified)
+		}
+	}
+}
@@ -4,19 +4,32 @@ import (
 	"fmt"
 )
 
-func makeObject(tok *tokenizer) *JsonObject {
-	stream := tok.run()
-	p := newParser(stream)
-	return p.parseJson()
+func makeObject(tok *tokenizer) (*JsonObject, error) {
+	return newParser(tok).parseJson()
 }
 
-func Load(fpath string) (*JsonObject, error) {
-	tok, err := newTokenizer(fpath)
+func makeValue(tok *tokenizer) (JsonElement, error) {
+	p := newParser(tok)
+
+	ele, err := p.parseValue()
 	if err != nil {
 		return nil, err
 	}
 
-	return makeObject(tok), nil
+	if err := p.parseEnd(); err != nil {
+		return nil, err
+	}
+	p.takeEndComments(ele)
+
+	return ele, nil
+}
+
+/*
+ * Load and Loads report a malformed document as a *NJsonError,
+ * DLoad and DLoads panic with it instead.
+ */
+func Load(fpath string) (*JsonObject, error) {
+	return defaultParseOptions.Load(fpath)
 }
 
 func DLoad(fpath string) *JsonObject {
@@ -30,12 +43,7 @@ func DLoad(fpath string) *JsonObject {
 }
 
 func Loads(source string) (*JsonObject, error) {
-	tok := &tokenizer{
-		filepath: "<source>",
-		source:   []byte(source),
-	}
-
-	return makeObject(tok), nil
+	return defaultParseOptions.Loads(source)
 }
 
 func DLoads(source string) *JsonObject {
@@ -48,6 +56,38 @@ func DLoads(source string) *JsonObject {
 	return obj
 }
 
+/*
+ * LoadValue and LoadsValue accept any value as the root of the document,
+ * use ObjectElement to get a *JsonObject when the root is a dict.
+ */
+func LoadValue(fpath string) (JsonElement, error) {
+	return defaultParseOptions.LoadValue(fpath)
+}
+
+func DLoadValue(fpath string) JsonElement {
+	ele, err := LoadValue(fpath)
+
+	if err != nil {
+		panic(err)
+	}
+
+	return ele
+}
+
+func LoadsValue(source string) (JsonElement, error) {
+	return defaultParseOptions.LoadsValue(source)
+}
+
+func DLoadsValue(source string) JsonElement {
+	ele, err := LoadsValue(source)
+
+	if err != nil {
+		panic(err)
+	}
+
+	return ele
+}
+
 func DGet(element JsonElement, indexOrKey interface{}) JsonElement {
 	ele, err := Get(element, indexOrKey)
 
@@ -164,3 +204,10 @@ func BoolElement(o JsonElement) *JsonBoolElement {
 	}
 	return nil
 }
+
+func ObjectElement(o JsonElement) *JsonObject {
+	if v, ok := o.(*JsonDictElement); ok {
+		return newJsonObjectFromDictElement(v)
+	}
+	return nil
+}
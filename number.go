@@ -0,0 +1,316 @@
+package njson
+
+import (
+	"math"
+	"math/big"
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+/*
+ * Number is the text of a json number. Unmarshal stores numbers read in
+ * UseNumber mode as a Number when the target is an interface{}, Marshal
+ * writes a Number back as it is.
+ */
+type Number string
+
+func (self Number) String() string { return string(self) }
+
+func (self Number) Int64() (int64, error) {
+	return strconv.ParseInt(string(self), 10, 64)
+}
+
+func (self Number) Float64() (float64, error) {
+	return strconv.ParseFloat(string(self), 64)
+}
+
+var useNumberOptions = &ParseOptions{UseNumber: true}
+
+/*
+ * NewJsonNumberElement makes an integer or float element from the text of a
+ * json number, the text is kept and written back exactly.
+ */
+func NewJsonNumberElement(s string) (JsonElement, error) {
+	tok := newTokenizerFromBytes("<number>", []byte(s), useNumberOptions)
+	p := newParser(tok)
+
+	t := p.nowTok().tokenType
+	if t != _T_INTEGER && t != _T_FLOAT {
+		return nil, p.syntaxError("except number")
+	}
+
+	ele, err := p.parseElement()
+	if err != nil {
+		return nil, err
+	}
+
+	if p.nowTok().tokenType != _T_EOF {
+		return nil, p.syntaxError("except end of number")
+	}
+
+	return ele, nil
+}
+
+// lexeme returns the text the number was read from, "" when it was not kept.
+func (self *JsonIntegerElement) lexeme() string { return self.slot.raw }
+func (self *JsonFloatElement) lexeme() string   { return self.slot.raw }
+
+type numberLexeme interface {
+	lexeme() string
+}
+
+func lexemeOf(element JsonElement) string {
+	if n, ok := element.(numberLexeme); ok {
+		return n.lexeme()
+	}
+	return ""
+}
+
+// ToBigInt returns the exact value, also when it is out of the int64 range.
+func (self *JsonIntegerElement) ToBigInt() *big.Int {
+	if self.slot.raw != "" {
+		if n, ok := new(big.Int).SetString(self.slot.raw, 10); ok {
+			return n
+		}
+	}
+	return big.NewInt(self.slot.vint)
+}
+
+func (self *JsonIntegerElement) ToBigFloat() *big.Float {
+	return numberBigFloat(self)
+}
+
+func (self *JsonIntegerElement) ToDecimalString() string {
+	if self.slot.raw != "" {
+		return self.slot.raw
+	}
+	return strconv.FormatInt(self.slot.vint, 10)
+}
+
+// ToBigInt returns the value when it is a whole number (e.g. 1.5e3), else nil.
+func (self *JsonFloatElement) ToBigInt() *big.Int {
+	s := self.ToDecimalString()
+
+	intPart, frac := s, ""
+	if i := strings.IndexByte(s, '.'); i >= 0 {
+		intPart, frac = s[:i], s[i+1:]
+	}
+	if strings.Trim(frac, "0") != "" {
+		return nil
+	}
+
+	n, ok := new(big.Int).SetString(intPart, 10)
+	if !ok {
+		return nil
+	}
+	return n
+}
+
+/*
+ * ToBigFloat returns the value with enough precision to tell apart any two
+ * numbers of the same length. it is nil for NaN.
+ */
+func (self *JsonFloatElement) ToBigFloat() *big.Float {
+	return numberBigFloat(self)
+}
+
+/*
+ * ToDecimalString returns the value without exponent, digits of the original
+ * text (like the trailing zero of 12.50) are kept.
+ */
+func (self *JsonFloatElement) ToDecimalString() string {
+	if self.slot.raw != "" {
+		return plainDecimal(self.slot.raw)
+	}
+	return strconv.FormatFloat(self.slot.vfloat, 'f', -1, 64)
+}
+
+// exponents larger than this are not expanded by plainDecimal
+const maxPlainExponent = 1 << 16
+
+// plainDecimal moves the decimal point of a json number text by its exponent.
+func plainDecimal(s string) string {
+	i := strings.IndexAny(s, "eE")
+	if i < 0 {
+		return s
+	}
+
+	mant := s[:i]
+	exp, err := strconv.Atoi(s[i+1:])
+	if err != nil || exp > maxPlainExponent || exp < -maxPlainExponent {
+		return s
+	}
+
+	sign := ""
+	if mant[0] == '-' {
+		sign, mant = "-", mant[1:]
+	}
+
+	intPart, frac := mant, ""
+	if j := strings.IndexByte(mant, '.'); j >= 0 {
+		intPart, frac = mant[:j], mant[j+1:]
+	}
+
+	digits := intPart + frac
+	point := len(intPart) + exp
+
+	if point <= 0 {
+		digits = strings.Repeat("0", 1-point) + digits
+		point = 1
+	}
+	if point >= len(digits) {
+		digits += strings.Repeat("0", point-len(digits))
+		return sign + trimLeadingZeros(digits)
+	}
+
+	return sign + trimLeadingZeros(digits[:point]) + "." + digits[point:]
+}
+
+func trimLeadingZeros(s string) string {
+	s = strings.TrimLeft(s, "0")
+	if s == "" {
+		return "0"
+	}
+	return s
+}
+
+// numberBigFloat converts an integer or float element, nil for NaN.
+func numberBigFloat(element JsonElement) *big.Float {
+	if raw := lexemeOf(element); raw != "" {
+		prec := uint(len(raw))*4 + 64
+		if f, _, err := big.ParseFloat(raw, 10, prec, big.ToNearestEven); err == nil {
+			return f
+		}
+	}
+
+	if element.Type() == ELE_INTEGER {
+		return new(big.Float).SetInt64(element.ToInteger64())
+	}
+
+	f := element.ToFloat64()
+	if math.IsNaN(f) {
+		return nil
+	}
+	return new(big.Float).SetFloat64(f)
+}
+
+// numberFloat64 returns the value of an integer or float element at float64 precision.
+func numberFloat64(element JsonElement) float64 {
+	if raw := lexemeOf(element); raw != "" {
+		// out of range gives +-Inf, the sign is right for the comparison
+		f, _ := strconv.ParseFloat(raw, 64)
+		return f
+	}
+
+	if element.Type() == ELE_INTEGER {
+		return float64(element.ToInteger64())
+	}
+	return element.ToFloat64()
+}
+
+/*
+ * compareNumbers orders two integer or float elements by value, ok is false
+ * when one of them is NaN. numbers with kept text are compared exactly, but a
+ * float read without UseNumber only holds the nearest float64, so text
+ * compared with it is rounded to float64 too: "8.95" equals the float 8.95.
+ */
+func compareNumbers(a, b JsonElement) (int, bool) {
+	rawA, rawB := lexemeOf(a), lexemeOf(b)
+
+	if rawA == "" && rawB == "" && a.Type() == ELE_INTEGER && b.Type() == ELE_INTEGER {
+		x, y := a.ToInteger64(), b.ToInteger64()
+		switch {
+		case x < y:
+			return -1, true
+		case x > y:
+			return 1, true
+		}
+		return 0, true
+	}
+
+	if (rawA == "" && rawB != "" && a.Type() == ELE_FLOAT) ||
+		(rawB == "" && rawA != "" && b.Type() == ELE_FLOAT) {
+		x, y := numberFloat64(a), numberFloat64(b)
+		switch {
+		case math.IsNaN(x) || math.IsNaN(y):
+			return 0, false
+		case x < y:
+			return -1, true
+		case x > y:
+			return 1, true
+		}
+		return 0, true
+	}
+
+	x, y := numberBigFloat(a), numberBigFloat(b)
+	if x == nil || y == nil {
+		return 0, false
+	}
+	return x.Cmp(y), true
+}
+
+// integerBig returns the exact value of an integer element.
+func integerBig(element JsonElement) *big.Int {
+	if n, ok := element.(*JsonIntegerElement); ok {
+		return n.ToBigInt()
+	}
+	return big.NewInt(element.ToInteger64())
+}
+
+var (
+	numberType   = reflect.TypeOf(Number(""))
+	bigIntType   = reflect.TypeOf(big.Int{})
+	bigFloatType = reflect.TypeOf(big.Float{})
+)
+
+// numberText returns the json text of a Number, big.Int or big.Float value.
+func numberText(v reflect.Value) (string, bool) {
+	switch v.Type() {
+
+	case numberType:
+		return v.String(), true
+
+	case bigIntType:
+		if v.CanAddr() {
+			return v.Addr().Interface().(*big.Int).String(), true
+		}
+		n := v.Interface().(big.Int)
+		return n.String(), true
+
+	case bigFloatType:
+		var f *big.Float
+		if v.CanAddr() {
+			f = v.Addr().Interface().(*big.Float)
+		} else {
+			x := v.Interface().(big.Float)
+			f = &x
+		}
+
+		s := f.Text('g', -1)
+		// Text writes a whole big.Float as "3", the ".0" reads it back as a float element
+		if !strings.ContainsAny(s, ".eI") {
+			s += ".0"
+		}
+		return s, true
+	}
+
+	return "", false
+}
+
+// numberString returns the text of an integer or float element.
+func numberString(element JsonElement) string {
+	if raw := lexemeOf(element); raw != "" {
+		return raw
+	}
+
+	if element.Type() == ELE_INTEGER {
+		return strconv.FormatInt(element.ToInteger64(), 10)
+	}
+
+	s, err := formatFloat(element.ToFloat64())
+	if err != nil {
+		return strconv.FormatFloat(element.ToFloat64(), 'g', -1, 64)
+	}
+	return s
+}
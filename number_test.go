@@ -0,0 +1,115 @@
+package njson
+
+import (
+	"testing"
+)
+
+// a float read without UseNumber compared with the same number read with UseNumber
+func TestMixedModeNumbers(t *testing.T) {
+	cases := []struct {
+		plain, text string
+		cmp         int
+	}{
+		{"8.95", "8.95", 0},
+		{"0.1", "0.1", 0},
+		{"1e-7", "0.0000001", 0},
+		{"1.5", "1.50", 0},
+		{"8.95", "8.96", -1},
+		{"8.95", "8.94", 1},
+		{"1.0", "1", 0},
+		{"2.5", "100000000000000000000", -1},
+		{"1", "1.0", 0},
+		{"5", "100000000000000000000", -1},
+	}
+
+	for _, c := range cases {
+		plain, err := LoadsValue(c.plain)
+		if err != nil {
+			t.Fatal(err)
+		}
+		text, err := useNumberOptions.LoadsValue(c.text)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if got, ok := compareNumbers(plain, text); !ok || got != c.cmp {
+			t.Errorf("compareNumbers(%s, %s) = %d, %v, want %d", c.plain, c.text, got, ok, c.cmp)
+		}
+		if got, ok := compareNumbers(text, plain); !ok || got != -c.cmp {
+			t.Errorf("compareNumbers(%s, %s) = %d, %v, want %d", c.text, c.plain, got, ok, -c.cmp)
+		}
+
+		numeric := &EqualOptions{Numeric: true}
+		if got := numeric.Equal(plain, text); got != (c.cmp == 0) {
+			t.Errorf("numeric Equal(%s, %s) = %v", c.plain, c.text, got)
+		}
+	}
+}
+
+func TestMixedModeEqual(t *testing.T) {
+	src := `{"p": 8.95, "q": [0.1, 1, "x"]}`
+
+	plain, err := LoadsValue(src)
+	if err != nil {
+		t.Fatal(err)
+	}
+	text, err := useNumberOptions.LoadsValue(src)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !Equal(plain, text) || Compare(plain, text) != 0 {
+		t.Errorf("the same document read in both modes differs")
+	}
+	if patch := Diff(plain, text); len(patch) != 0 {
+		t.Errorf("Diff of the same document: %v", patch)
+	}
+
+	test, _ := LoadsValue(`[{"op": "test", "path": "/p", "value": 8.95}]`)
+	patch, err := ParsePatch(test)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := patch.Apply(text); err != nil {
+		t.Errorf("test op: %v", err)
+	}
+}
+
+func TestMixedModeJsonPathFilter(t *testing.T) {
+	doc, err := LoadsValue(`[8.95, 0.1, 1]`)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cases := []struct {
+		expr string
+		want []string
+	}{
+		{"$[?@ == 0.1]", []string{"$[1]"}},
+		{"$[?@ >= 8.95]", []string{"$[0]"}},
+		{"$[?@ < 8.95]", []string{"$[1]", "$[2]"}},
+		{"$[?@ == 1.0]", []string{"$[2]"}},
+	}
+
+	for _, c := range cases {
+		nodes, err := QueryJsonPath(doc, c.expr)
+		if err != nil {
+			t.Fatalf("%s: %v", c.expr, err)
+		}
+
+		got := []string{}
+		for _, n := range nodes {
+			got = append(got, n.Path)
+		}
+		if len(got) != len(c.want) {
+			t.Errorf("%s = %v, want %v", c.expr, got, c.want)
+			continue
+		}
+		for i := range got {
+			if got[i] != c.want[i] {
+				t.Errorf("%s = %v, want %v", c.expr, got, c.want)
+				break
+			}
+		}
+	}
+}
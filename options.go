@@ -0,0 +1,140 @@
+package njson
+
+import (
+	"io"
+)
+
+// SurrogatePolicy decides what a \uXXXX escape of an unpaired UTF-16 surrogate becomes.
+type SurrogatePolicy int
+
+const (
+	// the string is rejected
+	SurrogateError SurrogatePolicy = iota
+	// the surrogate is replaced with U+FFFD
+	SurrogateReplace
+	// the surrogate is kept, encoded as WTF-8
+	SurrogateWTF8
+)
+
+// DuplicateKeyPolicy decides what happens when a key appears twice in a dict.
+type DuplicateKeyPolicy int
+
+const (
+	// the last value is kept, the key stays where it first appeared
+	DuplicateLastWins DuplicateKeyPolicy = iota
+	// the first value is kept, later ones are ignored
+	DuplicateFirstWins
+	// the document is rejected, the error tells where both keys are
+	DuplicateError
+	// every value is kept, see JsonDictElement.GetAll. Get returns the last one
+	DuplicateKeepAll
+)
+
+/*
+ * ParseOptions changes how documents are read. the zero value (or a nil
+ * pointer) parses strict RFC 8259 json, the package level Load functions
+ * use it.
+ */
+type ParseOptions struct {
+	LoneSurrogates SurrogatePolicy
+	DuplicateKeys  DuplicateKeyPolicy
+
+	// keep the text of every number, integers out of the int64 range are
+	// accepted and numbers are written back exactly as they were read.
+	UseNumber bool
+
+	// '//' and '/* */' comments
+	AllowComments bool
+	// a ',' after the last item of an array or dict
+	AllowTrailingCommas bool
+
+	/*
+	 * JSON5 accepts every JSON5 extension: comments, trailing commas,
+	 * single quoted strings, identifier keys, hex numbers, a leading '+',
+	 * .5 and 5., Infinity, NaN, strings continued on the next line and
+	 * control characters other than line breaks (a tab) in strings.
+	 */
+	JSON5 bool
+
+	/*
+	 * limits for untrusted input, each one is reported with its own error
+	 * code (ErrDepthLimit, ErrInputLimit...). 0 means no limit, except for
+	 * MaxDepth where 0 means DefaultMaxDepth and a negative value no limit.
+	 */
+	MaxDepth         int // nesting of arrays and dicts
+	MaxInputBytes    int // size of the input, for a Decoder the whole stream
+	MaxStringLength  int // bytes of a decoded string or key
+	MaxNumberLength  int // bytes of a number
+	MaxKeysPerObject int // members of a dict, repeated keys included
+	MaxElements      int // elements of a document, for a Decoder of each value
+}
+
+// DefaultMaxDepth keeps deeply nested input from exhausting the stack.
+const DefaultMaxDepth = 10000
+
+func (self *ParseOptions) maxDepth() int {
+	if self.MaxDepth == 0 {
+		return DefaultMaxDepth
+	}
+	return self.MaxDepth
+}
+
+var defaultParseOptions = &ParseOptions{}
+
+func (self *ParseOptions) orDefault() *ParseOptions {
+	if self == nil {
+		return defaultParseOptions
+	}
+	return self
+}
+
+func (self *ParseOptions) comments() bool {
+	return self.AllowComments || self.JSON5
+}
+
+func (self *ParseOptions) trailingCommas() bool {
+	return self.AllowTrailingCommas || self.JSON5
+}
+
+func (self *ParseOptions) Load(fpath string) (*JsonObject, error) {
+	tok, err := newTokenizer(fpath, self.orDefault())
+	if err != nil {
+		return nil, err
+	}
+
+	return makeObject(tok)
+}
+
+func (self *ParseOptions) Loads(source string) (*JsonObject, error) {
+	tok := newTokenizerFromBytes("<source>", []byte(source), self.orDefault())
+
+	return makeObject(tok)
+}
+
+func (self *ParseOptions) LoadValue(fpath string) (JsonElement, error) {
+	tok, err := newTokenizer(fpath, self.orDefault())
+	if err != nil {
+		return nil, err
+	}
+
+	return makeValue(tok)
+}
+
+func (self *ParseOptions) LoadsValue(source string) (JsonElement, error) {
+	tok := newTokenizerFromBytes("<source>", []byte(source), self.orDefault())
+
+	return makeValue(tok)
+}
+
+func (self *ParseOptions) NewDecoder(r io.Reader) *Decoder {
+	return &Decoder{
+		p: newParser(newStreamTokenizer("<stream>", r, self.orDefault())),
+	}
+}
+
+func (self *ParseOptions) NewLineDecoder(r io.Reader) *Decoder {
+	dec := self.NewDecoder(r)
+	dec.lines = true
+
+	return dec
+}
@@ -0,0 +1,144 @@
+package njson
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestLimitErrorCodes(t *testing.T) {
+	cases := []struct {
+		opts   ParseOptions
+		src    string
+		code   ErrorCode
+		column int
+	}{
+		{ParseOptions{MaxDepth: 2}, `[[[1]]]`, ErrDepthLimit, 3},
+		{ParseOptions{MaxInputBytes: 5}, `{"a": 1}`, ErrInputLimit, 6},
+		{ParseOptions{MaxStringLength: 3}, `["abcd"]`, ErrStringLimit, 6},
+		{ParseOptions{MaxStringLength: 3}, `{"abcd": 1}`, ErrStringLimit, 6},
+		{ParseOptions{MaxNumberLength: 3}, `[1234]`, ErrNumberLimit, 5},
+		{ParseOptions{MaxNumberLength: 3}, `[-123]`, ErrNumberLimit, 5},
+		{ParseOptions{MaxNumberLength: 3}, `[123.4]`, ErrNumberLimit, 5},
+		{ParseOptions{MaxNumberLength: 3}, `[12e4]`, ErrNumberLimit, 5},
+		{ParseOptions{MaxKeysPerObject: 1}, `{"a": 1, "b": 2}`, ErrKeysLimit, 10},
+		{ParseOptions{MaxElements: 2}, `[1, 2, 3]`, ErrElementLimit, 5},
+		{ParseOptions{JSON5: true, MaxStringLength: 3}, `{abcd: 1}`, ErrStringLimit, 5},
+		{ParseOptions{JSON5: true, MaxNumberLength: 4}, `[0x1234]`, ErrNumberLimit, 6},
+		{ParseOptions{JSON5: true, MaxNumberLength: 3}, `[+1234]`, ErrNumberLimit, 5},
+	}
+
+	for _, c := range cases {
+		opts := c.opts
+		_, err := opts.LoadsValue(c.src)
+
+		var e *NJsonError
+		if !errors.As(err, &e) || e.Code != c.code {
+			t.Errorf("%s %+v: got %v, want %v", c.src, c.opts, err, c.code)
+			continue
+		}
+		if e.Column != c.column {
+			t.Errorf("%s %+v: error at column %d, want %d", c.src, c.opts, e.Column, c.column)
+		}
+	}
+
+	// within the limits
+	opts := &ParseOptions{MaxDepth: 3, MaxStringLength: 4, MaxNumberLength: 4, MaxKeysPerObject: 2, MaxElements: 5}
+	if _, err := opts.LoadsValue(`{"abcd": [1234], "b": -1.5}`); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestMaxInputBytes(t *testing.T) {
+	src := `{"a": [1, 2, 3]}`
+	small := &ParseOptions{MaxInputBytes: len(src) - 1}
+	exact := &ParseOptions{MaxInputBytes: len(src)}
+
+	dir, err := ioutil.TempDir("", "njson")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	fpath := filepath.Join(dir, "doc.json")
+	if err := ioutil.WriteFile(fpath, []byte(src), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	loaders := map[string]func(opts *ParseOptions) error{
+		"Load": func(opts *ParseOptions) error {
+			_, err := opts.Load(fpath)
+			return err
+		},
+		"Loads": func(opts *ParseOptions) error {
+			_, err := opts.Loads(src)
+			return err
+		},
+		"Decoder": func(opts *ParseOptions) error {
+			_, err := opts.NewDecoder(strings.NewReader(src)).Decode()
+			return err
+		},
+	}
+
+	for name, load := range loaders {
+		if err := load(small); !errors.Is(err, ErrInputLimit) {
+			t.Errorf("%s: got %v, want %v", name, err, ErrInputLimit)
+		}
+		if err := load(exact); err != nil {
+			t.Errorf("%s: %v", name, err)
+		}
+	}
+}
+
+// endlessReader returns prefix then fill forever, it counts the bytes read.
+type endlessReader struct {
+	prefix string
+	fill   byte
+	read   int
+}
+
+func (self *endlessReader) Read(p []byte) (int, error) {
+	n := copy(p, self.prefix)
+	self.prefix = self.prefix[n:]
+	for i := n; i < len(p); i++ {
+		p[i] = self.fill
+	}
+	self.read += len(p)
+	return len(p), nil
+}
+
+// a limit stops reading where it is crossed, not at the end of the token
+func TestLimitStopsReading(t *testing.T) {
+	cases := []struct {
+		opts   ParseOptions
+		r      *endlessReader
+		code   ErrorCode
+		column int
+	}{
+		{ParseOptions{MaxNumberLength: 10}, &endlessReader{prefix: "[", fill: '1'}, ErrNumberLimit, 12},
+		{ParseOptions{MaxNumberLength: 10}, &endlessReader{prefix: "[1.", fill: '5'}, ErrNumberLimit, 12},
+		{ParseOptions{MaxNumberLength: 10, JSON5: true}, &endlessReader{prefix: "[0x", fill: 'f'}, ErrNumberLimit, 12},
+		{ParseOptions{MaxStringLength: 10}, &endlessReader{prefix: `["`, fill: 'a'}, ErrStringLimit, 13},
+		{ParseOptions{MaxStringLength: 10, JSON5: true}, &endlessReader{prefix: "{", fill: 'a'}, ErrStringLimit, 12},
+	}
+
+	for _, c := range cases {
+		opts, prefix := c.opts, c.r.prefix
+		_, err := opts.NewDecoder(c.r).Decode()
+
+		var e *NJsonError
+		if !errors.As(err, &e) || e.Code != c.code {
+			t.Errorf("%q: got %v, want %v", prefix, err, c.code)
+			continue
+		}
+		if e.Column != c.column {
+			t.Errorf("%q: error at column %d, want %d", prefix, e.Column, c.column)
+		}
+		if c.r.read > 4*jsonBufferChunk {
+			t.Errorf("%q: %d bytes read", prefix, c.r.read)
+		}
+	}
+}
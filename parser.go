@@ -6,92 +6,128 @@ import (
 )
 
 type parser struct {
-	stream   *tokenStream
+	lexer    *tokenizer
 	filename string
 	source   []byte
 
-	_tp int
+	cur    *token
+	last   *token // the token eaten last
+	lexErr *NJsonError
+
+	depth    int // arrays and dicts being parsed
+	elements int // elements parsed so far
 }
 
-func newParser(tokenStream *tokenStream) *parser {
+func newParser(lexer *tokenizer) *parser {
 	return &parser{
-		stream:   tokenStream,
-		filename: tokenStream.filepath,
-		source:   tokenStream.source,
+		lexer:    lexer,
+		filename: lexer.filepath,
+		source:   lexer.buf.source(),
 	}
 }
 
-func (self *parser) nextTok() *token {
-	self._tp++
-	return self.stream.At(self._tp)
+// nextTok drops the current token, the next one is read on demand so the
+// parser never reads further than the value it is parsing.
+func (self *parser) nextTok() {
+	if self.cur != nil {
+		self.last = self.cur
+	}
+	self.cur = nil
 }
 
 func (self *parser) nowTok() *token {
-	return self.stream.At(self._tp)
-}
+	if self.cur != nil {
+		return self.cur
+	}
 
-func (self *parser) setTp(index int) {
-	self._tp = index
-}
+	tok, err := self.lexer.next()
+	if err != nil {
+		// the error is reported by the first syntaxError on this token
+		self.lexErr = err.(*NJsonError)
+		tok = self.lexer.makeToken("", _T_ERROR)
+	}
+	self.cur = tok
 
-func (self *parser) movTp(step int) {
-	self._tp += step
+	return tok
 }
 
-func (self *parser) peek(step int) *token {
-	return self.stream.At(self._tp + step)
+// shortLexeme cuts a long number in an error message, MaxNumberLength may be unset.
+func shortLexeme(lexeme string) string {
+	if len(lexeme) <= 32 {
+		return lexeme
+	}
+	return lexeme[:32] + "..."
 }
 
-func (self *parser) parseInteger() *JsonIntegerElement {
+func (self *parser) parseInteger() (*JsonIntegerElement, error) {
 	nt := self.nowTok()
-	if nt.tokenType == _T_INTEGER {
-		self.nextTok()
+	if nt.tokenType != _T_INTEGER {
+		return nil, self.syntaxError("except integer")
+	}
 
-		v, err := strconv.ParseInt(nt.value, 10, 64)
-		if err != nil {
-			fmt.Println(err)
-		}
-		return &JsonIntegerElement{
-			slot: &_JsonNumberSlot{
-				vint: int64(v),
-			},
+	useNumber := self.lexer.opts.UseNumber
+
+	v, err := strconv.ParseInt(nt.value, 10, 64)
+	if err != nil {
+		if !useNumber {
+			return nil, self.handleError(errorf(ErrNumberRange, "integer out of range : %s (set ParseOptions.UseNumber to keep it)", shortLexeme(nt.value)))
 		}
+		// v is saturated, the exact value is kept in raw
 	}
-	return nil
+	self.nextTok()
+
+	slot := &_JsonNumberSlot{
+		vint: int64(v),
+	}
+	if useNumber {
+		slot.raw = nt.value
+	}
+
+	return &JsonIntegerElement{
+		slot: slot,
+	}, nil
 }
 
-func (self *parser) parseFloat() *JsonFloatElement {
+func (self *parser) parseFloat() (*JsonFloatElement, error) {
 	nt := self.nowTok()
-	if nt.tokenType == _T_FLOAT {
-		self.nextTok()
+	if nt.tokenType != _T_FLOAT {
+		return nil, self.syntaxError("except float")
+	}
 
-		v, err := strconv.ParseFloat(nt.value, 64)
-		if err != nil {
-			fmt.Println(err)
-		}
-		return &JsonFloatElement{
-			slot: &_JsonNumberSlot{
-				vfloat: float64(v),
-			},
-		}
+	useNumber := self.lexer.opts.UseNumber
+
+	v, err := strconv.ParseFloat(nt.value, 64)
+	if err != nil && !useNumber {
+		return nil, self.handleError(errorf(ErrNumberRange, "float out of range : %s (set ParseOptions.UseNumber to keep it)", shortLexeme(nt.value)))
 	}
-	return nil
+	self.nextTok()
+
+	slot := &_JsonNumberSlot{
+		vfloat: float64(v),
+	}
+	// Infinity and NaN have no json text
+	if useNumber && isDigit(nt.value[len(nt.value)-1]) {
+		slot.raw = nt.value
+	}
+
+	return &JsonFloatElement{
+		slot: slot,
+	}, nil
 }
 
-func (self *parser) parseString() *JsonStringElement {
+func (self *parser) parseString() (*JsonStringElement, error) {
 	nt := self.nowTok()
-	if nt.tokenType == _T_STRING {
-		self.nextTok()
-
-		v := nt.value
-		return &JsonStringElement{
-			value: v,
-		}
+	if nt.tokenType != _T_STRING {
+		return nil, self.syntaxError("except string")
 	}
-	return nil
+	self.nextTok()
+
+	return &JsonStringElement{
+		value: nt.value,
+	}, nil
 }
 
-func (self *parser) parseBool() *JsonBoolElement {
+func (self *parser) parseBool() (*JsonBoolElement, error) {
 	nt := self.nowTok()
 	v := true
 	if nt.tokenType == _T_FALSE {
@@ -100,156 +136,400 @@ func (self *parser) parseBool() *JsonBoolElement {
 	self.nextTok()
 	return &JsonBoolElement{
 		value: v,
+	}, nil
+}
+
+func (self *parser) handleError(err error) *NJsonError {
+	nt := self.nowTok()
+
+	if nt.tokenType == _T_ERROR {
+		return self.lexErr
 	}
+
+	code := codeOf(err, ErrUnexpectedToken)
+	if code == ErrUnexpectedToken && nt.tokenType == _T_EOF {
+		code = ErrUnexpectedEOF
+	}
+
+	return self.tokenError(nt, code, err.Error())
 }
 
-func (self *parser) handleError(err error) {
-	msg := err.Error()
+func (self *parser) tokenError(tok *token, code ErrorCode, msg string) *NJsonError {
+	return &NJsonError{
+		File:       self.filename,
+		Line:       tok.span.Start.Line,
+		Column:     tok.span.Start.Column,
+		ByteOffset: tok.span.Start.Offset,
+		Code:       code,
+		Message:    msg,
+		source:     self.source,
+	}
+}
+
+func (self *parser) syntaxError(msg string) *NJsonError {
+	return self.handleError(errorf(ErrUnexpectedToken, "%s", msg))
+}
+
+// takeComments returns the comments read before the current token, they are returned once.
+func (self *parser) takeComments() []*comment {
 	nt := self.nowTok()
+	cs := nt.comments
+	nt.comments = nil
 
-	njerr := &NJsonError{
-		filepath: self.filename,
-		lno:      nt.lineno,
-		offset:   nt.offset,
-		message:  msg,
-		source:   self.source,
+	return cs
+}
+
+// enter counts an opened array or dict against ParseOptions.MaxDepth.
+func (self *parser) enter() error {
+	self.depth++
+
+	if max := self.lexer.opts.maxDepth(); max > 0 && self.depth > max {
+		return self.handleError(errorf(ErrDepthLimit, "nesting is deeper than %d", max))
 	}
-	njerr.ThrowError()
+	return nil
 }
 
-func (self *parser) syntaxError(msg string) {
-	self.handleError(fmt.Errorf(msg))
+func (self *parser) leave() {
+	self.depth--
 }
 
-func (self *parser) parseArray() *JsonArrayElement {
+func (self *parser) parseArray() (*JsonArrayElement, error) {
 	itemList := []JsonElement{}
 
+	if err := self.enter(); err != nil {
+		return nil, err
+	}
+	defer self.leave()
+
 	self.nextTok() // eat '['
 
 	if self.nowTok().Equals("]") {
-		self.nextTok() // eat ']'
-		return &JsonArrayElement{
+		array := &JsonArrayElement{
 			array: []JsonElement{},
 		}
+		attachComments(array, self.takeComments(), commentTrailing)
+		self.nextTok() // eat ']'
+		return array, nil
 	}
 
-	fitem := self.parseElement()
-	if fitem == nil {
-		self.syntaxError("except Element or ']'")
+	lead := self.takeComments()
+	fitem, err := self.parseElement()
+	if err != nil {
+		return nil, err
 	}
+	attachComments(fitem, lead, commentLeading)
 
 	itemList = append(itemList, fitem)
 
-	var item JsonElement
-
 	for self.nowTok().Equals(",") {
+		prev := itemList[len(itemList)-1]
+		attachAfter(prev, self.takeComments())
+
 		self.nextTok() // eat  ','
-		item = self.parseElement()
-		if item == nil {
-			self.syntaxError("except Element or ']'")
+		same, lead := splitComments(self.takeComments())
+		attachComments(prev, same, commentInline)
+
+		if self.lexer.opts.trailingCommas() && self.nowTok().Equals("]") {
+			attachComments(prev, lead, commentTrailing)
+			break
+		}
+		item, err := self.parseElement()
+		if err != nil {
+			return nil, err
 		}
+		attachComments(item, lead, commentLeading)
 		itemList = append(itemList, item)
 	}
 
-	if self.nowTok().Equals("]") {
-		self.nextTok() // eat ']'
-	} else {
-		self.syntaxError("except ']'")
+	if !self.nowTok().Equals("]") {
+		return nil, self.syntaxError("except ']'")
 	}
+	attachAfter(itemList[len(itemList)-1], self.takeComments())
+	self.nextTok() // eat ']'
 
 	return &JsonArrayElement{
 		array: itemList,
-	}
+	}, nil
 }
 
-func (self *parser) parseNull() *JsonNullElement {
+func (self *parser) parseNull() (*JsonNullElement, error) {
 	self.nextTok() // eat 'null'
-	return &JsonNullElement{}
+	return &JsonNullElement{}, nil
 }
 
-func (self *parser) parseKVPair() (string, JsonElement) {
-	key := self.parseString()
+// isIdentToken reports whether a JSON5 identifier was read as nt.
+func isIdentToken(nt *token) bool {
+	switch nt.tokenType {
+	case _T_NAME, _T_TRUE, _T_FALSE, _T_NULL:
+		return true
+	case _T_FLOAT:
+		return isIdentStart(nt.value[0])
+	}
+	return false
+}
 
-	if key == nil {
-		self.syntaxError("except string")
+func (self *parser) parseKey() (string, error) {
+	if nt := self.nowTok(); self.lexer.opts.JSON5 && isIdentToken(nt) {
+		self.nextTok()
+		return nt.value, nil
+	}
+
+	key, err := self.parseString()
+	if err != nil {
+		return "", err
+	}
+	return key.value, nil
+}
+
+func (self *parser) parseKVPair() (string, JsonElement, error) {
+	key, err := self.parseKey()
+	if err != nil {
+		return "", nil, err
 	}
 
 	if !self.nowTok().Equals(":") {
-		self.syntaxError("except ':'")
+		return "", nil, self.syntaxError("except ':'")
 	}
+	between := self.takeComments()
 
 	self.nextTok() // eat ':'
+	between = append(between, self.takeComments()...)
 
-	ele := self.parseElement()
-
-	if ele == nil {
-		self.syntaxError("except JsonElement")
+	ele, err := self.parseElement()
+	if err != nil {
+		return "", nil, err
 	}
+	attachComments(ele, between, commentInline)
 
-	return key.value, ele
+	return key, ele, nil
 }
 
-func (self *parser) parseDict() *JsonDictElement {
+func (self *parser) parseDict() (*JsonDictElement, error) {
+	if !self.nowTok().Equals("{") {
+		return nil, self.syntaxError("except '{'")
+	}
+
+	if err := self.enter(); err != nil {
+		return nil, err
+	}
+	defer self.leave()
+
 	self.nextTok() // eat '{'
 
-	m := map[string]JsonElement{}
-	keys := []string{}
+	dict := &JsonDictElement{
+		dict:     map[string]JsonElement{},
+		keySpans: map[string]Span{},
+	}
 
 	if self.nowTok().Equals("}") {
+		attachComments(dict, self.takeComments(), commentTrailing)
 		self.nextTok()
-		return &JsonDictElement{
-			dict: m,
-		}
+		return dict, nil
+	}
+
+	members := &dictMembers{
+		first: map[string]*token{},
 	}
 
-	fk, fv := self.parseKVPair()
-	m[fk] = fv
+	lead := self.takeComments()
+	keyTok := self.nowTok()
+	fk, fv, err := self.parseKVPair()
+	if err != nil {
+		return nil, err
+	}
+	attachComments(fv, lead, commentLeading)
+	if err := self.addMember(dict, members, keyTok, fk, fv); err != nil {
+		return nil, err
+	}
 
-	keys = append(keys, fk)
+	prev := fv
 
 	for self.nowTok().Equals(",") {
+		attachAfter(prev, self.takeComments())
+
 		self.nextTok() // eat ','
-		k, v := self.parseKVPair()
-		m[k] = v
-		keys = append(keys, k)
+		same, lead := splitComments(self.takeComments())
+		attachComments(prev, same, commentInline)
+
+		if self.lexer.opts.trailingCommas() && self.nowTok().Equals("}") {
+			attachComments(prev, lead, commentTrailing)
+			break
+		}
+		keyTok := self.nowTok()
+		k, v, err := self.parseKVPair()
+		if err != nil {
+			return nil, err
+		}
+		attachComments(v, lead, commentLeading)
+		if err := self.addMember(dict, members, keyTok, k, v); err != nil {
+			return nil, err
+		}
+		prev = v
 	}
 
 	if !self.nowTok().Equals("}") {
-		self.syntaxError("except '}'")
+		return nil, self.syntaxError("except '}'")
 	}
+	attachAfter(prev, self.takeComments())
 
 	self.nextTok() // eat '}'
 
-	return &JsonDictElement{
-		dict: m,
-		keys: keys,
+	return dict, nil
+}
+
+// dictMembers is what parseDict knows about the members read so far.
+type dictMembers struct {
+	first map[string]*token // key token of the first occurrence of each key
+	count int               // members read, repeated keys included
+}
+
+// addMember adds a parsed member to dict, a repeated key is handled as ParseOptions.DuplicateKeys says.
+func (self *parser) addMember(dict *JsonDictElement, members *dictMembers, keyTok *token, k string, v JsonElement) error {
+	members.count++
+	if max := self.lexer.opts.MaxKeysPerObject; max > 0 && members.count > max {
+		return self.tokenError(keyTok, ErrKeysLimit, fmt.Sprintf("dict has more than %d keys", max))
+	}
+
+	first, ok := members.first[k]
+	if !ok {
+		members.first[k] = keyTok
+		dict.dict[k] = v
+		dict.keys = append(dict.keys, k)
+		dict.keySpans[k] = keyTok.span
+		return nil
+	}
+
+	switch self.lexer.opts.DuplicateKeys {
+
+	case DuplicateFirstWins:
+		// keep the first value
+
+	case DuplicateError:
+		return self.tokenError(keyTok, ErrDuplicateKey, fmt.Sprintf("duplicate key %s, first defined at line %d column %d",
+			strconv.Quote(k), first.lineno, first.offset))
+
+	case DuplicateKeepAll:
+		if dict.dups == nil {
+			dict.dups = map[string][]JsonElement{}
+		}
+		if _, ok := dict.dups[k]; !ok {
+			dict.dups[k] = []JsonElement{dict.dict[k]}
+		}
+		dict.dups[k] = append(dict.dups[k], v)
+		dict.dict[k] = v
+
+	default:
+		dict.dict[k] = v
 	}
+
+	return nil
 }
 
-func (self *parser) parseElement() JsonElement {
+func (self *parser) parseElement() (JsonElement, error) {
 	nt := self.nowTok()
 
+	self.elements++
+	if max := self.lexer.opts.MaxElements; max > 0 && self.elements > max && nt.tokenType != _T_ERROR {
+		return nil, self.handleError(errorf(ErrElementLimit, "document has more than %d elements", max))
+	}
+
+	var ele JsonElement
+	var err error
+
 	switch nt.tokenType {
 
 	case _T_STRING:
-		return self.parseString()
+		ele, err = self.parseString()
 	case _T_INTEGER:
-		return self.parseInteger()
+		ele, err = self.parseInteger()
 	case _T_FLOAT:
-		return self.parseFloat()
+		ele, err = self.parseFloat()
 	case _T_FALSE, _T_TRUE:
-		return self.parseBool()
+		ele, err = self.parseBool()
 	case _T_MLBASKET:
-		return self.parseArray()
+		ele, err = self.parseArray()
 	case _T_LLBASKET:
-		return self.parseDict()
+		ele, err = self.parseDict()
 	case _T_NULL:
-		return self.parseNull()
+		ele, err = self.parseNull()
+	default:
+		return nil, self.syntaxError("except JsonElement")
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	// from the first token of the element to the last one
+	ele.(baseHolder).base().span = Span{
+		Start: nt.span.Start,
+		End:   self.last.span.End,
+	}
+
+	return ele, nil
+}
+
+func (self *parser) parseValue() (JsonElement, error) {
+	lead := self.takeComments()
+
+	ele, err := self.parseElement()
+	if err != nil {
+		return nil, err
+	}
+	attachComments(ele, lead, commentLeading)
+
+	return ele, nil
+}
+
+// parseEnd checks that nothing but white space (and comments) follows the root element.
+func (self *parser) parseEnd() error {
+	msg := "except end of input, found trailing content after the root element"
+
+	nt := self.nowTok()
+	if nt.tokenType == _T_ERROR {
+		switch self.lexErr.Code {
+		case ErrRead, ErrInputLimit, ErrStringLimit, ErrNumberLimit:
+			return self.lexErr
+		}
+
+		// `{"a":1} garbage` is trailing content, not an invalid character, at the same position
+		err := *self.lexErr
+		err.Code = ErrTrailingContent
+		err.Message = msg + " : " + self.lexErr.Message
+		return &err
 	}
 
+	if nt.tokenType != _T_EOF {
+		return self.handleError(errorf(ErrTrailingContent, "%s", msg))
+	}
 	return nil
 }
 
-func (self *parser) parseJson() *JsonObject {
-	return newJsonObjectFromDictElement(self.parseDict())
+// takeEndComments attaches the comments after the root element of a document.
+func (self *parser) takeEndComments(root JsonElement) {
+	if !self.lexer.opts.comments() || self.nowTok().tokenType != _T_EOF {
+		return
+	}
+	attachAfter(root, self.takeComments())
+}
+
+func (self *parser) parseJson() (*JsonObject, error) {
+	if !self.nowTok().Equals("{") {
+		return nil, self.syntaxError("except '{', the root element is not a dict (use LoadValue to load any value)")
+	}
+	lead := self.takeComments()
+
+	ele, err := self.parseElement()
+	if err != nil {
+		return nil, err
+	}
+	dict := ele.(*JsonDictElement)
+	attachComments(dict, lead, commentLeading)
+
+	if err := self.parseEnd(); err != nil {
+		return nil, err
+	}
+	self.takeEndComments(dict)
+
+	return newJsonObjectFromDictElement(dict), nil
 }
@@ -0,0 +1,60 @@
+package njson
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestTrailingContent(t *testing.T) {
+	cases := []struct {
+		src    string
+		code   ErrorCode
+		column int
+	}{
+		{`{"a":1} garbage`, ErrTrailingContent, 9},
+		{`{"a":1} {"b":2}`, ErrTrailingContent, 9},
+		{`{"a":1} ]`, ErrTrailingContent, 9},
+		{`{"a":1} "x`, ErrTrailingContent, 11}, // where the lexer stopped
+		{`{"a":1} // comment`, ErrTrailingContent, 9},
+		{`{"a":1} `, 0, 0},
+	}
+
+	for _, c := range cases {
+		_, err := Loads(c.src)
+		if c.code == 0 {
+			if err != nil {
+				t.Errorf("%q: %v", c.src, err)
+			}
+			continue
+		}
+
+		var e *NJsonError
+		if !errors.Is(err, c.code) || !errors.As(err, &e) {
+			t.Errorf("%q: got %v, want %v", c.src, err, c.code)
+			continue
+		}
+		if e.Line != 1 || e.Column != c.column {
+			t.Errorf("%q: error at %d:%d, want 1:%d", c.src, e.Line, e.Column, c.column)
+		}
+	}
+}
+
+func TestNumberRangeMessage(t *testing.T) {
+	cases := []string{
+		"1" + strings.Repeat("0", 5000),
+		"1e" + strings.Repeat("9", 5000),
+	}
+
+	for _, src := range cases {
+		_, err := LoadsValue(src)
+		var e *NJsonError
+		if !errors.As(err, &e) || e.Code != ErrNumberRange {
+			t.Errorf("got %v, want %v", err, ErrNumberRange)
+			continue
+		}
+		if len(e.Message) > 100 || !strings.Contains(e.Message, src[:32]+"...") {
+			t.Errorf("message not cut : %.120s", e.Message)
+		}
+	}
+}
@@ -0,0 +1,309 @@
+package njson
+
+import (
+	"fmt"
+)
+
+// operations of a JSON Patch (RFC 6902)
+const (
+	PatchAdd     = "add"
+	PatchRemove  = "remove"
+	PatchReplace = "replace"
+	PatchMove    = "move"
+	PatchCopy    = "copy"
+	PatchTest    = "test"
+)
+
+/*
+ * PatchOp is one operation of a JSON Patch. From is only used by move and
+ * copy, Value only by add, replace and test.
+ */
+type PatchOp struct {
+	Op    string
+	Path  Pointer
+	From  Pointer
+	Value JsonElement
+}
+
+// Patch is a JSON Patch document (RFC 6902), its operations are applied in order.
+type Patch []PatchOp
+
+// Element returns the operation as a JSON Patch object, like {"op": "remove", "path": "/a"}.
+func (self PatchOp) Element() *JsonDictElement {
+	dict := NewJsonDictElement()
+	dict.set("op", NewJsonStringElement(self.Op))
+	dict.set("path", NewJsonStringElement(self.Path.String()))
+
+	switch self.Op {
+	case PatchMove, PatchCopy:
+		dict.set("from", NewJsonStringElement(self.From.String()))
+	case PatchAdd, PatchReplace, PatchTest:
+		dict.set("value", self.Value)
+	}
+
+	return dict
+}
+
+// Element returns the patch as a JSON Patch document, ready for Dumps.
+func (self Patch) Element() *JsonArrayElement {
+	array := NewJsonArrayElement()
+
+	for _, op := range self {
+		array.add(op.Element())
+	}
+
+	return array
+}
+
+/*
+ * PatchError tells which operation of a patch could not be parsed or
+ * applied, Index starts at 0 and is -1 when the patch itself is malformed.
+ */
+type PatchError struct {
+	Index   int
+	Op      string
+	Path    string
+	Message string
+
+	cause error // the *PointerError of a path that could not be resolved
+}
+
+func (self *PatchError) Error() string {
+	if self.Index < 0 {
+		return "json patch: " + self.Message
+	}
+	if self.Op == "" {
+		return fmt.Sprintf("json patch operation %d: %s", self.Index, self.Message)
+	}
+	return fmt.Sprintf("json patch operation %d (%s %q): %s", self.Index, self.Op, self.Path, self.Message)
+}
+
+func (self *PatchError) Unwrap() error {
+	return self.cause
+}
+
+var patchOps = map[string]bool{
+	PatchAdd: true, PatchRemove: true, PatchReplace: true,
+	PatchMove: true, PatchCopy: true, PatchTest: true,
+}
+
+func parsePatchOp(element JsonElement) (PatchOp, string) {
+	op := PatchOp{}
+
+	if element.Type() != ELE_DICT {
+		return op, "an operation must be a dict, got " + elementTypeName(element)
+	}
+	dict := element.ToDict()
+
+	pointer := func(member string) (Pointer, string) {
+		v, ok := dict[member]
+		if !ok {
+			return nil, "missing \"" + member + "\""
+		}
+		if v.Type() != ELE_STRING {
+			return nil, "\"" + member + "\" must be a string"
+		}
+		p, err := ParsePointer(v.ToString())
+		if err != nil {
+			return nil, err.Error()
+		}
+		return p, ""
+	}
+
+	if v, ok := dict["op"]; !ok || v.Type() != ELE_STRING || !patchOps[v.ToString()] {
+		return op, "\"op\" must be one of add, remove, replace, move, copy or test"
+	} else {
+		op.Op = v.ToString()
+	}
+
+	var msg string
+	if op.Path, msg = pointer("path"); msg != "" {
+		return op, msg
+	}
+
+	switch op.Op {
+	case PatchMove, PatchCopy:
+		if op.From, msg = pointer("from"); msg != "" {
+			return op, msg
+		}
+	case PatchAdd, PatchReplace, PatchTest:
+		v, ok := dict["value"]
+		if !ok {
+			return op, "missing \"value\""
+		}
+		op.Value = v
+	}
+
+	return op, ""
+}
+
+// ParsePatch reads a JSON Patch document, an array of operations like {"op": "add", "path": "/a", "value": 1}.
+func ParsePatch(element JsonElement) (Patch, error) {
+	if element == nil || element.Type() != ELE_ARRAY {
+		return nil, &PatchError{Index: -1, Message: "a patch must be an array of operations"}
+	}
+
+	patch := Patch{}
+	for i, item := range element.ToElementArray() {
+		op, msg := parsePatchOp(item)
+		if msg != "" {
+			path := ""
+			if v, ok := item.ToDict()["path"]; ok && v.Type() == ELE_STRING {
+				path = v.ToString()
+			}
+			return nil, &PatchError{Index: i, Op: op.Op, Path: path, Message: msg}
+		}
+		patch = append(patch, op)
+	}
+
+	return patch, nil
+}
+
+// locate returns the dict or array holding the last token of path, and the array index.
+func locate(doc JsonElement, path Pointer, adding bool) (JsonElement, int, error) {
+	parent, err := path[:len(path)-1].Resolve(doc)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	if adding && parent.Type() == ELE_DICT {
+		return parent, 0, nil
+	}
+	_, index, err := path.step(parent, len(path)-1, adding)
+	return parent, index, err
+}
+
+func patchAdd(doc JsonElement, path Pointer, value JsonElement) (JsonElement, error) {
+	if len(path) == 0 {
+		return value, nil
+	}
+
+	parent, index, err := locate(doc, path, true)
+	if err != nil {
+		return nil, err
+	}
+
+	if dict, ok := parent.(*JsonDictElement); ok {
+		return doc, dict.Set(path[len(path)-1], value)
+	}
+	return doc, parent.(*JsonArrayElement).Insert(index, value)
+}
+
+func patchRemove(doc JsonElement, path Pointer) (JsonElement, error) {
+	if len(path) == 0 {
+		return nil, fmt.Errorf("can not remove the root")
+	}
+
+	parent, index, err := locate(doc, path, false)
+	if err != nil {
+		return nil, err
+	}
+
+	if dict, ok := parent.(*JsonDictElement); ok {
+		removed := dict.dict[path[len(path)-1]]
+		dict.Delete(path[len(path)-1])
+		return removed, nil
+	}
+	return parent.(*JsonArrayElement).RemoveAt(index)
+}
+
+func patchReplace(doc JsonElement, path Pointer, value JsonElement) (JsonElement, error) {
+	if len(path) == 0 {
+		return value, nil
+	}
+
+	parent, index, err := locate(doc, path, false)
+	if err != nil {
+		return nil, err
+	}
+
+	if dict, ok := parent.(*JsonDictElement); ok {
+		return doc, dict.Set(path[len(path)-1], value)
+	}
+	return doc, parent.(*JsonArrayElement).SetAt(index, value)
+}
+
+// isPrefix reports whether the pointer is a proper prefix of b.
+func (self Pointer) isPrefix(b Pointer) bool {
+	if len(self) >= len(b) {
+		return false
+	}
+	for i := range self {
+		if self[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+func (self PatchOp) apply(doc JsonElement) (JsonElement, error) {
+	switch self.Op {
+
+	case PatchAdd:
+		return patchAdd(doc, self.Path, cloneElement(nullIfNil(self.Value)))
+
+	case PatchRemove:
+		_, err := patchRemove(doc, self.Path)
+		return doc, err
+
+	case PatchReplace:
+		return patchReplace(doc, self.Path, cloneElement(nullIfNil(self.Value)))
+
+	case PatchMove:
+		if self.From.String() == self.Path.String() {
+			_, err := self.From.Resolve(doc)
+			return doc, err
+		}
+		if self.From.isPrefix(self.Path) {
+			return nil, fmt.Errorf("can not move %q into itself", self.From.String())
+		}
+		value, err := patchRemove(doc, self.From)
+		if err != nil {
+			return nil, err
+		}
+		return patchAdd(doc, self.Path, value)
+
+	case PatchCopy:
+		value, err := self.From.Resolve(doc)
+		if err != nil {
+			return nil, err
+		}
+		return patchAdd(doc, self.Path, cloneElement(value))
+
+	case PatchTest:
+		value, err := self.Path.Resolve(doc)
+		if err != nil {
+			return nil, err
+		}
+		if !equalElements(value, nullIfNil(self.Value), true) {
+			text, _ := Dumps(value)
+			return nil, fmt.Errorf("test failed, the value is %s", text)
+		}
+		return doc, nil
+	}
+
+	return nil, fmt.Errorf("unknown operation %q", self.Op)
+}
+
+/*
+ * Apply applies the operations in order and returns the patched document.
+ * it works on a copy, doc is left unchanged when an operation fails and the
+ * *PatchError tells which one.
+ */
+func (self Patch) Apply(doc JsonElement) (JsonElement, error) {
+	doc = cloneElement(nullIfNil(doc))
+
+	for i, op := range self {
+		patched, err := op.apply(doc)
+		if err != nil {
+			pe := &PatchError{Index: i, Op: op.Op, Path: op.Path.String(), Message: err.Error()}
+			if _, ok := err.(*PointerError); ok {
+				pe.cause = err
+			}
+			return nil, pe
+		}
+		doc = patched
+	}
+
+	return doc, nil
+}
@@ -0,0 +1,187 @@
+package njson
+
+import (
+	"errors"
+	"testing"
+)
+
+func mustPatch(t *testing.T, src string) Patch {
+	t.Helper()
+
+	element, err := LoadsValue(src)
+	if err != nil {
+		t.Fatal(err)
+	}
+	patch, err := ParsePatch(element)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return patch
+}
+
+// RFC 6902 Appendix A, the failing examples have want == ""
+func TestPatchApply(t *testing.T) {
+	cases := []struct {
+		doc, patch, want string
+	}{
+		{`{"foo":"bar"}`, `[{"op":"add","path":"/baz","value":"qux"}]`, `{"foo":"bar","baz":"qux"}`},
+		{`{"foo":["bar","baz"]}`, `[{"op":"add","path":"/foo/1","value":"qux"}]`, `{"foo":["bar","qux","baz"]}`},
+		{`{"baz":"qux","foo":"bar"}`, `[{"op":"remove","path":"/baz"}]`, `{"foo":"bar"}`},
+		{`{"foo":["bar","qux","baz"]}`, `[{"op":"remove","path":"/foo/1"}]`, `{"foo":["bar","baz"]}`},
+		{`{"baz":"qux","foo":"bar"}`, `[{"op":"replace","path":"/baz","value":"boo"}]`, `{"baz":"boo","foo":"bar"}`},
+		{`{"foo":{"bar":"baz","waldo":"fred"},"qux":{"corge":"grault"}}`,
+			`[{"op":"move","from":"/foo/waldo","path":"/qux/thud"}]`,
+			`{"foo":{"bar":"baz"},"qux":{"corge":"grault","thud":"fred"}}`},
+		{`{"foo":["all","grass","cows","eat"]}`, `[{"op":"move","from":"/foo/1","path":"/foo/3"}]`, `{"foo":["all","cows","eat","grass"]}`},
+		{`{"baz":"qux","foo":["a",2,"c"]}`,
+			`[{"op":"test","path":"/baz","value":"qux"},{"op":"test","path":"/foo/1","value":2}]`,
+			`{"baz":"qux","foo":["a",2,"c"]}`},
+		{`{"baz":"qux"}`, `[{"op":"test","path":"/baz","value":"bar"}]`, ``},
+		{`{"foo":"bar"}`, `[{"op":"add","path":"/child","value":{"grandchild":{}}}]`, `{"foo":"bar","child":{"grandchild":{}}}`},
+		{`{"foo":"bar"}`, `[{"op":"add","path":"/baz/bat","value":"qux"}]`, ``},
+		{`{"/":9,"~1":10}`, `[{"op":"test","path":"/~01","value":10}]`, `{"/":9,"~1":10}`},
+		{`{"/":9,"~1":10}`, `[{"op":"test","path":"/~01","value":"10"}]`, ``},
+		{`{"foo":["bar"]}`, `[{"op":"add","path":"/foo/-","value":["abc","def"]}]`, `{"foo":["bar",["abc","def"]]}`},
+
+		// "-" is the end of an array, only add can use it
+		{`[1,2]`, `[{"op":"add","path":"/-","value":3}]`, `[1,2,3]`},
+		{`[1,2]`, `[{"op":"remove","path":"/-"}]`, ``},
+		{`[1,2]`, `[{"op":"replace","path":"/-","value":3}]`, ``},
+		{`[1,2]`, `[{"op":"test","path":"/-","value":2}]`, ``},
+		{`[1,2]`, `[{"op":"copy","from":"/-","path":"/0"}]`, ``},
+		{`[1,2]`, `[{"op":"add","path":"/2","value":3}]`, `[1,2,3]`},
+		{`[1,2]`, `[{"op":"add","path":"/3","value":3}]`, ``},
+
+		// the root
+		{`{"a":1}`, `[{"op":"replace","path":"","value":[1]}]`, `[1]`},
+		{`{"a":1}`, `[{"op":"add","path":"","value":2}]`, `2`},
+		{`{"a":1}`, `[{"op":"remove","path":""}]`, ``},
+
+		// moving into itself
+		{`{"a":{"b":1}}`, `[{"op":"move","from":"/a","path":"/a/c"}]`, ``},
+		{`{"a":{"b":1}}`, `[{"op":"move","from":"/a","path":"/a"}]`, `{"a":{"b":1}}`},
+		{`{"a":{"b":1}}`, `[{"op":"move","from":"/x","path":"/x"}]`, ``},
+		{`{"a":{"b":1},"ab":2}`, `[{"op":"move","from":"/a","path":"/ab"}]`, `{"ab":{"b":1}}`},
+		{`{"a":{"b":1}}`, `[{"op":"copy","from":"/a","path":"/a/c"}]`, `{"a":{"b":1,"c":{"b":1}}}`},
+	}
+
+	for _, c := range cases {
+		doc, err := LoadsValue(c.doc)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		patched, err := mustPatch(t, c.patch).Apply(doc)
+		if c.want == "" {
+			if err == nil {
+				s, _ := Dumps(patched)
+				t.Errorf("%s on %s = %s, want an error", c.patch, c.doc, s)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s on %s: %v", c.patch, c.doc, err)
+			continue
+		}
+		if s, _ := Dumps(patched); s != c.want {
+			t.Errorf("%s on %s = %s, want %s", c.patch, c.doc, s, c.want)
+		}
+	}
+}
+
+func TestPatchAtomic(t *testing.T) {
+	doc, _ := LoadsValue(`{"a":[1,2],"b":{"c":3}}`)
+	patch := mustPatch(t, `[
+		{"op":"add","path":"/a/-","value":3},
+		{"op":"remove","path":"/b/c"},
+		{"op":"replace","path":"/x","value":1},
+		{"op":"add","path":"/d","value":4}
+	]`)
+
+	_, err := patch.Apply(doc)
+
+	var pe *PatchError
+	if !errors.As(err, &pe) {
+		t.Fatalf("got %v, want a *PatchError", err)
+	}
+	if pe.Index != 2 || pe.Op != PatchReplace || pe.Path != "/x" {
+		t.Errorf("got %+v", pe)
+	}
+	var pointerErr *PointerError
+	if !errors.As(err, &pointerErr) {
+		t.Errorf("the *PointerError of /x is not wrapped: %v", err)
+	}
+
+	if s, _ := Dumps(doc); s != `{"a":[1,2],"b":{"c":3}}` {
+		t.Errorf("a failed patch changed the document: %s", s)
+	}
+
+	// a failing test op leaves the document as it was too
+	patch = mustPatch(t, `[{"op":"remove","path":"/a"},{"op":"test","path":"/b/c","value":4}]`)
+	_, err = patch.Apply(doc)
+	if !errors.As(err, &pe) || pe.Index != 1 || pe.Op != PatchTest {
+		t.Errorf("got %v", err)
+	}
+	if s, _ := Dumps(doc); s != `{"a":[1,2],"b":{"c":3}}` {
+		t.Errorf("a failed patch changed the document: %s", s)
+	}
+}
+
+func TestParsePatchErrors(t *testing.T) {
+	cases := []struct {
+		src   string
+		index int
+	}{
+		{`{"op":"add"}`, -1},
+		{`[1]`, 0},
+		{`[{"op":"test","path":"/a","value":1},{"op":"jump","path":"/a"}]`, 1},
+		{`[{"op":"add","path":"a","value":1}]`, 0},
+		{`[{"op":"add","path":"/a"}]`, 0},
+		{`[{"op":"move","path":"/a"}]`, 0},
+		{`[{"op":"remove","path":1}]`, 0},
+	}
+
+	for _, c := range cases {
+		element, err := LoadsValue(c.src)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		_, err = ParsePatch(element)
+		var pe *PatchError
+		if !errors.As(err, &pe) || pe.Index != c.index {
+			t.Errorf("%s: got %v, want an error at operation %d", c.src, err, c.index)
+		}
+	}
+}
+
+func TestJsonObjectApplyPatch(t *testing.T) {
+	obj, err := Loads(`{"a":1}`)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := obj.ApplyPatch(mustPatch(t, `[{"op":"add","path":"/b","value":2}]`)); err != nil {
+		t.Fatal(err)
+	}
+	if s, _ := obj.Dumps(); s != `{"a":1,"b":2}` {
+		t.Errorf("got %s", s)
+	}
+
+	// the root of a JsonObject stays a dict
+	err = obj.ApplyPatch(mustPatch(t, `[{"op":"replace","path":"","value":[1]}]`))
+	var pe *PatchError
+	if !errors.As(err, &pe) || pe.Index != -1 {
+		t.Errorf("got %v", err)
+	}
+	if s, _ := obj.Dumps(); s != `{"a":1,"b":2}` {
+		t.Errorf("a failed patch changed the object: %s", s)
+	}
+
+	if err := obj.ApplyPatch(mustPatch(t, `[{"op":"replace","path":"","value":{"c":3}}]`)); err != nil {
+		t.Fatal(err)
+	}
+	if s, _ := obj.Dumps(); s != `{"c":3}` {
+		t.Errorf("got %s", s)
+	}
+}
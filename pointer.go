@@ -0,0 +1,232 @@
+package njson
+
+import (
+	"strconv"
+	"strings"
+)
+
+/*
+ * Pointer is a parsed JSON Pointer (RFC 6901), one unescaped reference token
+ * per item. the empty pointer refers to the whole document.
+ */
+type Pointer []string
+
+/*
+ * PointerError tells which reference token of a pointer could not be
+ * resolved. Index is the position of that token, or -1 when the pointer
+ * itself is malformed.
+ */
+type PointerError struct {
+	Pointer string
+	Index   int
+	Token   string
+	Message string
+}
+
+func (self *PointerError) Error() string {
+	if self.Index < 0 {
+		return "json pointer " + strconv.Quote(self.Pointer) + ": " + self.Message
+	}
+	return "json pointer " + strconv.Quote(self.Pointer) +
+		": reference token " + strconv.Quote(self.Token) + ": " + self.Message
+}
+
+func unescapePointerToken(token string) (string, bool) {
+	if !strings.Contains(token, "~") {
+		return token, true
+	}
+
+	var buf strings.Builder
+
+	for i := 0; i < len(token); i++ {
+		if token[i] != '~' {
+			buf.WriteByte(token[i])
+			continue
+		}
+
+		if i+1 >= len(token) {
+			return "", false
+		}
+
+		switch token[i+1] {
+		case '0':
+			buf.WriteByte('~')
+		case '1':
+			buf.WriteByte('/')
+		default:
+			return "", false
+		}
+		i++
+	}
+
+	return buf.String(), true
+}
+
+func escapePointerToken(token string) string {
+	token = strings.Replace(token, "~", "~0", -1)
+	return strings.Replace(token, "/", "~1", -1)
+}
+
+func ParsePointer(s string) (Pointer, error) {
+	if s == "" {
+		return Pointer{}, nil
+	}
+
+	if s[0] != '/' {
+		return nil, &PointerError{
+			Pointer: s,
+			Index:   -1,
+			Message: "must be empty or start with '/'",
+		}
+	}
+
+	parts := strings.Split(s[1:], "/")
+	pointer := make(Pointer, len(parts))
+
+	for i, part := range parts {
+		token, ok := unescapePointerToken(part)
+		if !ok {
+			return nil, &PointerError{
+				Pointer: s,
+				Index:   i,
+				Token:   part,
+				Message: "invalid escape, '~' must be followed by '0' or '1'",
+			}
+		}
+		pointer[i] = token
+	}
+
+	return pointer, nil
+}
+
+func DParsePointer(s string) Pointer {
+	pointer, err := ParsePointer(s)
+
+	if err != nil {
+		panic(err)
+	}
+
+	return pointer
+}
+
+func (self Pointer) String() string {
+	var buf strings.Builder
+
+	for _, token := range self {
+		buf.WriteByte('/')
+		buf.WriteString(escapePointerToken(token))
+	}
+
+	return buf.String()
+}
+
+// Child returns a new pointer with token added at the end.
+func (self Pointer) Child(token string) Pointer {
+	child := make(Pointer, len(self)+1)
+	copy(child, self)
+	child[len(self)] = token
+
+	return child
+}
+
+// Parent returns the pointer without its last token, the root has no parent.
+func (self Pointer) Parent() (Pointer, bool) {
+	if len(self) == 0 {
+		return nil, false
+	}
+	return self[:len(self)-1], true
+}
+
+/*
+ * arrayIndex parses an array reference token. "-" (the nonexistent item
+ * after the last one) gives size when allowEnd is set.
+ */
+func arrayIndex(token string, size int, allowEnd bool) (int, string) {
+	if token == "-" {
+		if allowEnd {
+			return size, ""
+		}
+		return 0, "'-' refers to the nonexistent item after the last one"
+	}
+
+	if token == "" || (len(token) > 1 && token[0] == '0') {
+		return 0, "invalid array index"
+	}
+	for i := 0; i < len(token); i++ {
+		if token[i] < '0' || token[i] > '9' {
+			return 0, "invalid array index"
+		}
+	}
+
+	index, err := strconv.Atoi(token)
+	limit := size
+	if allowEnd {
+		limit = size + 1
+	}
+	if err != nil || index >= limit {
+		return 0, "array index out of range (size " + strconv.Itoa(size) + ")"
+	}
+
+	return index, ""
+}
+
+// step resolves the reference token at pos against a dict or array.
+func (self Pointer) step(element JsonElement, pos int, allowEnd bool) (JsonElement, int, error) {
+	token := self[pos]
+
+	fail := func(msg string) (JsonElement, int, error) {
+		return nil, 0, &PointerError{
+			Pointer: self.String(),
+			Index:   pos,
+			Token:   token,
+			Message: msg,
+		}
+	}
+
+	switch element.Type() {
+
+	case ELE_DICT:
+		v, ok := element.ToDict()[token]
+		if !ok {
+			return fail("key not found")
+		}
+		return v, 0, nil
+
+	case ELE_ARRAY:
+		items := element.ToElementArray()
+		index, msg := arrayIndex(token, len(items), allowEnd)
+		if msg != "" {
+			return fail(msg)
+		}
+		if index == len(items) {
+			return nil, index, nil
+		}
+		return items[index], index, nil
+	}
+
+	return fail("can not go into " + elementTypeName(element))
+}
+
+// Resolve returns the element the pointer refers to.
+func (self Pointer) Resolve(element JsonElement) (JsonElement, error) {
+	cur := element
+
+	for i := range self {
+		next, _, err := self.step(cur, i, false)
+		if err != nil {
+			return nil, err
+		}
+		cur = next
+	}
+
+	return cur, nil
+}
+
+func ResolvePointer(element JsonElement, pointer string) (JsonElement, error) {
+	p, err := ParsePointer(pointer)
+	if err != nil {
+		return nil, err
+	}
+
+	return p.Resolve(element)
+}
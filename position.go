@@ -0,0 +1,89 @@
+package njson
+
+import (
+	"fmt"
+)
+
+// Position is a place in the source. Line and Column (counted in bytes) start at 1, Offset is the byte offset from 0.
+type Position struct {
+	Line   int
+	Column int
+	Offset int
+}
+
+func (self Position) String() string {
+	return fmt.Sprintf("%d:%d", self.Line, self.Column)
+}
+
+// Span covers the source of an element or key, End is just after its last byte.
+type Span struct {
+	Start Position
+	End   Position
+}
+
+// IsValid reports whether the span was set by the parser.
+func (self Span) IsValid() bool {
+	return self.Start.Line > 0
+}
+
+func (self Span) String() string {
+	return self.Start.String() + "-" + self.End.String()
+}
+
+// SpanOf returns where element was read from, ok is false for an element that was not parsed.
+func SpanOf(element JsonElement) (Span, bool) {
+	if h, ok := element.(baseHolder); ok {
+		span := h.base().span
+		return span, span.IsValid()
+	}
+	return Span{}, false
+}
+
+// KeySpan returns where key was read from.
+func (self *JsonDictElement) KeySpan(key string) (Span, bool) {
+	span, ok := self.keySpans[key]
+	return span, ok
+}
+
+// SpanOf returns where the element a JSON Pointer refers to was read from.
+func (self *JsonObject) SpanOf(pointer string) (Span, error) {
+	element, err := self.ResolvePointer(pointer)
+	if err != nil {
+		return Span{}, err
+	}
+
+	span, ok := SpanOf(element)
+	if !ok {
+		return Span{}, fmt.Errorf("json pointer %q: the element was not read from the source", pointer)
+	}
+	return span, nil
+}
+
+// KeySpanOf returns where the last key of a JSON Pointer was read from.
+func (self *JsonObject) KeySpanOf(pointer string) (Span, error) {
+	p, err := ParsePointer(pointer)
+	if err != nil {
+		return Span{}, err
+	}
+
+	parent, ok := p.Parent()
+	if !ok {
+		return Span{}, fmt.Errorf("json pointer %q: the root has no key", pointer)
+	}
+
+	element, err := parent.Resolve(self._dict)
+	if err != nil {
+		return Span{}, err
+	}
+
+	dict, ok := element.(*JsonDictElement)
+	if !ok {
+		return Span{}, fmt.Errorf("json pointer %q: the parent is not a dict", pointer)
+	}
+
+	span, ok := dict.KeySpan(p[len(p)-1])
+	if !ok {
+		return Span{}, fmt.Errorf("json pointer %q: the key was not read from the source", pointer)
+	}
+	return span, nil
+}
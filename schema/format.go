@@ -0,0 +1,131 @@
+package schema
+
+import (
+	"net"
+	"net/mail"
+	"net/url"
+	"regexp"
+	"strings"
+	"time"
+
+	"njson"
+)
+
+// formats checks the values of the "format" keyword, unknown formats are not checked.
+var formats = map[string]func(string) bool{
+	"date-time":     isDateTime,
+	"date":          isDate,
+	"time":          isTime,
+	"email":         isEmail,
+	"hostname":      isHostname,
+	"ipv4":          isIPv4,
+	"ipv6":          isIPv6,
+	"uri":           isURI,
+	"uri-reference": isURIReference,
+	"uuid":          isUUID,
+	"regex":         isRegex,
+	"json-pointer":  isJSONPointer,
+}
+
+var (
+	timePattern = regexp.MustCompile(`^(\d{2}):(\d{2}):(\d{2})(\.\d+)?([Zz]|[+-]\d{2}:\d{2})$`)
+	uuidPattern = regexp.MustCompile(`^[0-9a-fA-F]{8}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{12}$`)
+	hostLabel   = regexp.MustCompile(`^[A-Za-z0-9]([A-Za-z0-9-]{0,61}[A-Za-z0-9])?$`)
+)
+
+// isDate checks a full-date of RFC 3339.
+func isDate(s string) bool {
+	if len(s) != 10 {
+		return false
+	}
+	_, err := time.Parse("2006-01-02", s)
+	return err == nil
+}
+
+// isTime checks a full-time of RFC 3339, a leap second is allowed.
+func isTime(s string) bool {
+	m := timePattern.FindStringSubmatch(s)
+	if m == nil {
+		return false
+	}
+
+	zone := strings.ToUpper(m[5])
+	if zone != "Z" {
+		if _, err := time.Parse("-07:00", zone); err != nil {
+			return false
+		}
+	}
+
+	seconds := m[3]
+	if seconds == "60" {
+		seconds = "59"
+	}
+	_, err := time.Parse("15:04:05", m[1]+":"+m[2]+":"+seconds)
+	return err == nil
+}
+
+func isDateTime(s string) bool {
+	i := strings.IndexAny(s, "Tt")
+	return i == 10 && isDate(s[:i]) && isTime(s[i+1:])
+}
+
+func isEmail(s string) bool {
+	addr, err := mail.ParseAddress(s)
+	return err == nil && addr.Address == s && addr.Name == ""
+}
+
+func isHostname(s string) bool {
+	s = strings.TrimSuffix(s, ".")
+	if s == "" || len(s) > 253 {
+		return false
+	}
+
+	for _, label := range strings.Split(s, ".") {
+		if !hostLabel.MatchString(label) {
+			return false
+		}
+	}
+	return true
+}
+
+func isIPv4(s string) bool {
+	parts := strings.Split(s, ".")
+	if len(parts) != 4 {
+		return false
+	}
+	for _, p := range parts {
+		// no leading zeros, they are read as octal by some tools
+		if len(p) > 1 && p[0] == '0' {
+			return false
+		}
+	}
+	return net.ParseIP(s) != nil
+}
+
+func isIPv6(s string) bool {
+	return strings.Contains(s, ":") && !strings.Contains(s, "%") && net.ParseIP(s) != nil
+}
+
+func isURI(s string) bool {
+	u, err := url.Parse(s)
+	return err == nil && u.IsAbs()
+}
+
+func isURIReference(s string) bool {
+	_, err := url.Parse(s)
+	return err == nil
+}
+
+func isUUID(s string) bool {
+	return uuidPattern.MatchString(s)
+}
+
+func isRegex(s string) bool {
+	_, err := regexp.Compile(s)
+	return err == nil
+}
+
+func isJSONPointer(s string) bool {
+	_, err := njson.ParsePointer(s)
+	return err == nil
+}
@@ -0,0 +1,344 @@
+package schema
+
+import (
+	"fmt"
+	"math/big"
+	"net/url"
+	"regexp"
+	"sort"
+
+	"njson"
+)
+
+// keywordCompiler reads the keywords of one schema object into its node.
+type keywordCompiler struct {
+	*compiler
+
+	n    *node
+	d    map[string]njson.JsonElement
+	base *url.URL
+	path string
+}
+
+func (self *keywordCompiler) errorf(keyword string, format string, a ...interface{}) error {
+	return schemaError(self.d[keyword], self.path+"/"+keyword, format, a...)
+}
+
+func (self *keywordCompiler) sub(keyword string) (*node, error) {
+	v, ok := self.d[keyword]
+	if !ok {
+		return nil, nil
+	}
+	return self.compile(v, self.base, self.path+"/"+keyword)
+}
+
+func (self *keywordCompiler) subArray(keyword string) ([]*node, error) {
+	v, ok := self.d[keyword]
+	if !ok {
+		return nil, nil
+	}
+	if v.Type() != njson.ELE_ARRAY || len(v.ToElementArray()) == 0 {
+		return nil, self.errorf(keyword, "must be a non-empty array of schemas")
+	}
+
+	nodes := []*node{}
+	for i, item := range v.ToElementArray() {
+		n, err := self.compile(item, self.base, fmt.Sprintf("%s/%s/%d", self.path, keyword, i))
+		if err != nil {
+			return nil, err
+		}
+		nodes = append(nodes, n)
+	}
+	return nodes, nil
+}
+
+func (self *keywordCompiler) subMap(keyword string) (map[string]*node, []string, error) {
+	v, ok := self.d[keyword]
+	if !ok {
+		return nil, nil, nil
+	}
+	if v.Type() != njson.ELE_DICT {
+		return nil, nil, self.errorf(keyword, "must be an object of schemas")
+	}
+
+	nodes := map[string]*node{}
+	keys := dictKeys(v)
+	for _, k := range keys {
+		n, err := self.compile(v.ToDict()[k], self.base, self.path+"/"+keyword+"/"+escapeToken(k))
+		if err != nil {
+			return nil, nil, err
+		}
+		nodes[k] = n
+	}
+	return nodes, keys, nil
+}
+
+func (self *keywordCompiler) count(keyword string) (*int, error) {
+	v, ok := self.d[keyword]
+	if !ok {
+		return nil, nil
+	}
+
+	r, ok := numberRat(v)
+	if !ok || !r.IsInt() || r.Sign() < 0 || !r.Num().IsInt64() {
+		return nil, self.errorf(keyword, "must be a non-negative integer")
+	}
+	n := int(r.Num().Int64())
+	return &n, nil
+}
+
+func (self *keywordCompiler) number(keyword string) (*big.Rat, error) {
+	v, ok := self.d[keyword]
+	if !ok {
+		return nil, nil
+	}
+
+	r, ok := numberRat(v)
+	if !ok {
+		return nil, self.errorf(keyword, "must be a number")
+	}
+	return r, nil
+}
+
+func (self *keywordCompiler) strings(keyword string) ([]string, error) {
+	v, ok := self.d[keyword]
+	if !ok {
+		return nil, nil
+	}
+	if v.Type() != njson.ELE_ARRAY {
+		return nil, self.errorf(keyword, "must be an array of strings")
+	}
+
+	list := []string{}
+	for _, item := range v.ToElementArray() {
+		if item.Type() != njson.ELE_STRING {
+			return nil, self.errorf(keyword, "must be an array of strings")
+		}
+		list = append(list, item.ToString())
+	}
+	return list, nil
+}
+
+var typeNames = map[string]bool{
+	"null": true, "boolean": true, "object": true, "array": true,
+	"number": true, "string": true, "integer": true,
+}
+
+func (self *keywordCompiler) compileTypes() error {
+	v, ok := self.d["type"]
+	if !ok {
+		return nil
+	}
+
+	if v.Type() == njson.ELE_STRING {
+		self.n.types = []string{v.ToString()}
+	} else {
+		list, err := self.strings("type")
+		if err != nil {
+			return err
+		}
+		self.n.types = list
+	}
+
+	for _, t := range self.n.types {
+		if !typeNames[t] {
+			return self.errorf("type", "unknown type %q", t)
+		}
+	}
+	return nil
+}
+
+func (self *keywordCompiler) compileRegexp(keyword string, pattern string) (*regexp.Regexp, error) {
+	re, err := regexp.Compile(pattern)
+	if err != nil {
+		return nil, self.errorf(keyword, "invalid pattern %q : %v", pattern, err)
+	}
+	return re, nil
+}
+
+func (self *keywordCompiler) run() error {
+	n, d := self.n, self.d
+	var err error
+
+	if v, ok := d["$ref"]; ok {
+		if v.Type() != njson.ELE_STRING {
+			return self.errorf("$ref", "must be a string")
+		}
+		self.refs = append(self.refs, pendingRef{n: n, ref: v.ToString(), base: self.base, path: self.path})
+	}
+
+	// the definitions are compiled so their errors are reported
+	if _, _, err = self.subMap("$defs"); err != nil {
+		return err
+	}
+
+	if err = self.compileTypes(); err != nil {
+		return err
+	}
+
+	if v, ok := d["enum"]; ok {
+		if v.Type() != njson.ELE_ARRAY {
+			return self.errorf("enum", "must be an array")
+		}
+		n.enum = v.ToElementArray()
+	}
+	if v, ok := d["const"]; ok {
+		n.konst = v
+	}
+	if v, ok := d["format"]; ok {
+		if v.Type() != njson.ELE_STRING {
+			return self.errorf("format", "must be a string")
+		}
+		n.format = v.ToString()
+	}
+
+	// numbers
+	if n.minimum, err = self.number("minimum"); err != nil {
+		return err
+	}
+	if n.maximum, err = self.number("maximum"); err != nil {
+		return err
+	}
+	if n.exclusiveMinimum, err = self.number("exclusiveMinimum"); err != nil {
+		return err
+	}
+	if n.exclusiveMaximum, err = self.number("exclusiveMaximum"); err != nil {
+		return err
+	}
+	if n.multipleOf, err = self.number("multipleOf"); err != nil {
+		return err
+	}
+	if n.multipleOf != nil && n.multipleOf.Sign() <= 0 {
+		return self.errorf("multipleOf", "must be greater than 0")
+	}
+
+	// strings
+	if n.minLength, err = self.count("minLength"); err != nil {
+		return err
+	}
+	if n.maxLength, err = self.count("maxLength"); err != nil {
+		return err
+	}
+	if v, ok := d["pattern"]; ok {
+		if v.Type() != njson.ELE_STRING {
+			return self.errorf("pattern", "must be a string")
+		}
+		if n.pattern, err = self.compileRegexp("pattern", v.ToString()); err != nil {
+			return err
+		}
+	}
+
+	// arrays
+	if n.prefixItems, err = self.subArray("prefixItems"); err != nil {
+		return err
+	}
+	if n.items, err = self.sub("items"); err != nil {
+		return err
+	}
+	if n.contains, err = self.sub("contains"); err != nil {
+		return err
+	}
+	if n.minContains, err = self.count("minContains"); err != nil {
+		return err
+	}
+	if n.maxContains, err = self.count("maxContains"); err != nil {
+		return err
+	}
+	if n.minItems, err = self.count("minItems"); err != nil {
+		return err
+	}
+	if n.maxItems, err = self.count("maxItems"); err != nil {
+		return err
+	}
+	if v, ok := d["uniqueItems"]; ok {
+		if v.Type() != njson.ELE_BOOL {
+			return self.errorf("uniqueItems", "must be a boolean")
+		}
+		n.uniqueItems = v.ToBool()
+	}
+
+	// objects
+	if n.properties, _, err = self.subMap("properties"); err != nil {
+		return err
+	}
+	if patterns, keys, err := self.subMap("patternProperties"); err != nil {
+		return err
+	} else {
+		for _, k := range keys {
+			re, err := self.compileRegexp("patternProperties", k)
+			if err != nil {
+				return err
+			}
+			n.patternProperties = append(n.patternProperties, &patternNode{re: re, schema: patterns[k]})
+		}
+	}
+	if n.additionalProperties, err = self.sub("additionalProperties"); err != nil {
+		return err
+	}
+	if n.propertyNames, err = self.sub("propertyNames"); err != nil {
+		return err
+	}
+	if n.required, err = self.strings("required"); err != nil {
+		return err
+	}
+	if v, ok := d["dependentRequired"]; ok {
+		if v.Type() != njson.ELE_DICT {
+			return self.errorf("dependentRequired", "must be an object of string arrays")
+		}
+		n.dependentRequired = map[string][]string{}
+		for k, list := range v.ToDict() {
+			names := []string{}
+			for _, item := range list.ToElementArray() {
+				if item.Type() != njson.ELE_STRING {
+					return self.errorf("dependentRequired", "must be an object of string arrays")
+				}
+				names = append(names, item.ToString())
+			}
+			n.dependentRequired[k] = names
+		}
+	}
+	if n.dependentSchemas, _, err = self.subMap("dependentSchemas"); err != nil {
+		return err
+	}
+	if n.minProperties, err = self.count("minProperties"); err != nil {
+		return err
+	}
+	if n.maxProperties, err = self.count("maxProperties"); err != nil {
+		return err
+	}
+
+	// applicators
+	if n.allOf, err = self.subArray("allOf"); err != nil {
+		return err
+	}
+	if n.anyOf, err = self.subArray("anyOf"); err != nil {
+		return err
+	}
+	if n.oneOf, err = self.subArray("oneOf"); err != nil {
+		return err
+	}
+	if n.not, err = self.sub("not"); err != nil {
+		return err
+	}
+	if n.ifNode, err = self.sub("if"); err != nil {
+		return err
+	}
+	if n.thenNode, err = self.sub("then"); err != nil {
+		return err
+	}
+	if n.elseNode, err = self.sub("else"); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// sortedKeys returns the keys of m in order, for stable messages.
+func sortedKeys(m map[string][]string) []string {
+	keys := []string{}
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,334 @@
+/*
+ * Package schema validates njson elements against a JSON Schema (draft
+ * 2020-12):
+ *
+ *	s, err := schema.CompileString(`{"type": "object", "required": ["port"]}`)
+ *	...
+ *	if err := s.Validate(doc); err != nil {
+ *		for _, v := range err.(*schema.ValidationError).Violations { ... }
+ *	}
+ *
+ * the supported keywords are $id, $anchor, $ref, $defs, type, enum, const,
+ * the number, string, array and object assertions, the applicators
+ * (properties, patternProperties, additionalProperties, propertyNames,
+ * prefixItems, items, contains, dependentSchemas, allOf, anyOf, oneOf,
+ * not, if / then / else), dependentRequired and format. other keywords
+ * are ignored. $ref only refers to schemas of the compiled document.
+ * patterns are Go regular expressions (RE2).
+ */
+package schema
+
+import (
+	"fmt"
+	"math/big"
+	"net/url"
+	"regexp"
+	"sort"
+	"strings"
+
+	"njson"
+)
+
+// node is a compiled schema, path is its location in the schema document as a JSON Pointer.
+type node struct {
+	path string
+
+	always *bool // a boolean schema
+
+	ref *node
+
+	types  []string
+	enum   []njson.JsonElement
+	konst  njson.JsonElement
+	format string
+
+	minimum, maximum                   *big.Rat
+	exclusiveMinimum, exclusiveMaximum *big.Rat
+	multipleOf                         *big.Rat
+
+	minLength, maxLength *int
+	pattern              *regexp.Regexp
+
+	prefixItems          []*node
+	items                *node
+	contains             *node
+	minContains          *int
+	maxContains          *int
+	minItems, maxItems   *int
+	uniqueItems          bool
+	properties           map[string]*node
+	patternProperties    []*patternNode
+	additionalProperties *node
+	propertyNames        *node
+	required             []string
+	dependentRequired    map[string][]string
+	dependentSchemas     map[string]*node
+	minProperties        *int
+	maxProperties        *int
+
+	allOf, anyOf, oneOf []*node
+	not                 *node
+	ifNode              *node
+	thenNode, elseNode  *node
+}
+
+type patternNode struct {
+	re     *regexp.Regexp
+	schema *node
+}
+
+// Schema is a compiled JSON Schema, it is safe to use from several goroutines.
+type Schema struct {
+	root *node
+}
+
+// location is where a schema element is in the document and the base URI its refs are resolved against.
+type location struct {
+	base *url.URL
+	path string
+}
+
+type compiler struct {
+	root      njson.JsonElement
+	nodes     map[njson.JsonElement]*node
+	locations map[njson.JsonElement]location
+	resources map[string]njson.JsonElement // by $id, and $id#anchor for anchors
+	refs      []pendingRef
+}
+
+type pendingRef struct {
+	n    *node
+	ref  string
+	base *url.URL
+	path string
+}
+
+// keywords whose value is a schema, a dict of schemas or an array of schemas
+var (
+	schemaKeywords = []string{"additionalProperties", "propertyNames", "items", "contains",
+		"not", "if", "then", "else", "unevaluatedItems", "unevaluatedProperties", "contentSchema"}
+	schemaMapKeywords   = []string{"properties", "patternProperties", "$defs", "definitions", "dependentSchemas"}
+	schemaArrayKeywords = []string{"allOf", "anyOf", "oneOf", "prefixItems"}
+)
+
+func escapeToken(token string) string {
+	return njson.Pointer{token}.String()[1:]
+}
+
+func schemaError(e njson.JsonElement, path string, format string, a ...interface{}) error {
+	msg := fmt.Sprintf(format, a...)
+
+	if path == "" {
+		path = "#"
+	}
+	if span, ok := njson.SpanOf(e); ok {
+		return fmt.Errorf("schema %s (line %d): %s", path, span.Start.Line, msg)
+	}
+	return fmt.Errorf("schema %s: %s", path, msg)
+}
+
+func dictKeys(e njson.JsonElement) []string {
+	if d, ok := e.(*njson.JsonDictElement); ok {
+		keys := []string{}
+		d.ForEach(func(k string, _ njson.JsonElement) {
+			keys = append(keys, k)
+		})
+		return keys
+	}
+
+	keys := []string{}
+	for k := range e.ToDict() {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func resolveID(base *url.URL, id string) (*url.URL, error) {
+	u, err := url.Parse(id)
+	if err != nil {
+		return nil, err
+	}
+	u = base.ResolveReference(u)
+	u.Fragment = ""
+	return u, nil
+}
+
+// scan records the location of every subschema and the resources named by $id and $anchor.
+func (self *compiler) scan(e njson.JsonElement, base *url.URL, path string) error {
+	if e.Type() != njson.ELE_DICT {
+		self.locations[e] = location{base: base, path: path}
+		return nil
+	}
+	d := e.ToDict()
+
+	if id, ok := d["$id"]; ok && id.Type() == njson.ELE_STRING {
+		u, err := resolveID(base, id.ToString())
+		if err != nil {
+			return schemaError(e, path, "invalid $id : %v", err)
+		}
+		base = u
+		self.resources[base.String()] = e
+	}
+	if anchor, ok := d["$anchor"]; ok && anchor.Type() == njson.ELE_STRING {
+		self.resources[base.String()+"#"+anchor.ToString()] = e
+	}
+
+	self.locations[e] = location{base: base, path: path}
+
+	for _, k := range schemaKeywords {
+		if sub, ok := d[k]; ok {
+			if err := self.scan(sub, base, path+"/"+k); err != nil {
+				return err
+			}
+		}
+	}
+	for _, k := range schemaMapKeywords {
+		if sub, ok := d[k]; ok && sub.Type() == njson.ELE_DICT {
+			for name, s := range sub.ToDict() {
+				if err := self.scan(s, base, path+"/"+k+"/"+escapeToken(name)); err != nil {
+					return err
+				}
+			}
+		}
+	}
+	for _, k := range schemaArrayKeywords {
+		if sub, ok := d[k]; ok && sub.Type() == njson.ELE_ARRAY {
+			for i, s := range sub.ToElementArray() {
+				if err := self.scan(s, base, fmt.Sprintf("%s/%s/%d", path, k, i)); err != nil {
+					return err
+				}
+			}
+		}
+	}
+
+	return nil
+}
+
+func (self *compiler) compile(e njson.JsonElement, base *url.URL, path string) (*node, error) {
+	if n, ok := self.nodes[e]; ok {
+		return n, nil
+	}
+
+	if loc, ok := self.locations[e]; ok {
+		base, path = loc.base, loc.path
+	}
+
+	n := &node{path: path}
+	self.nodes[e] = n
+
+	switch e.Type() {
+	case njson.ELE_BOOL:
+		b := e.ToBool()
+		n.always = &b
+		return n, nil
+	case njson.ELE_DICT:
+	default:
+		return nil, schemaError(e, path, "a schema must be an object or a boolean")
+	}
+
+	d := e.ToDict()
+	if id, ok := d["$id"]; ok && id.Type() == njson.ELE_STRING {
+		if u, err := resolveID(base, id.ToString()); err == nil {
+			base = u
+		}
+	}
+
+	c := &keywordCompiler{compiler: self, n: n, d: d, base: base, path: path}
+	if err := c.run(); err != nil {
+		return nil, err
+	}
+
+	return n, nil
+}
+
+// resolve finds the schema a $ref refers to.
+func (self *compiler) resolve(p pendingRef) (*node, error) {
+	u, err := url.Parse(p.ref)
+	if err != nil {
+		return nil, fmt.Errorf("schema %s/$ref: invalid reference %q", p.path, p.ref)
+	}
+	u = p.base.ResolveReference(u)
+
+	fragment := u.Fragment
+	u.Fragment = ""
+
+	resource, ok := self.resources[u.String()]
+	if !ok {
+		return nil, fmt.Errorf("schema %s/$ref: unknown schema %q, only the compiled document can be referred to", p.path, u.String())
+	}
+
+	target := resource
+	switch {
+	case fragment == "":
+	case strings.HasPrefix(fragment, "/"):
+		pointer, err := njson.ParsePointer(fragment)
+		if err == nil {
+			target, err = pointer.Resolve(resource)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("schema %s/$ref: %v", p.path, err)
+		}
+	default:
+		target, ok = self.resources[u.String()+"#"+fragment]
+		if !ok {
+			return nil, fmt.Errorf("schema %s/$ref: unknown anchor %q", p.path, fragment)
+		}
+	}
+
+	loc, ok := self.locations[resource]
+	if !ok {
+		loc = location{base: u}
+	}
+	return self.compile(target, loc.base, loc.path+fragment)
+}
+
+// Compile compiles a schema loaded by njson, the document is not changed and may be shared.
+func Compile(element njson.JsonElement) (*Schema, error) {
+	self := &compiler{
+		root:      element,
+		nodes:     map[njson.JsonElement]*node{},
+		locations: map[njson.JsonElement]location{},
+		resources: map[string]njson.JsonElement{},
+	}
+
+	base := &url.URL{}
+	self.resources[""] = element
+
+	if err := self.scan(element, base, ""); err != nil {
+		return nil, err
+	}
+
+	root, err := self.compile(element, base, "")
+	if err != nil {
+		return nil, err
+	}
+
+	// refs are resolved last, they may refer to any schema of the document
+	for i := 0; i < len(self.refs); i++ {
+		p := self.refs[i]
+		target, err := self.resolve(p)
+		if err != nil {
+			return nil, err
+		}
+		p.n.ref = target
+	}
+
+	return &Schema{root: root}, nil
+}
+
+func CompileString(source string) (*Schema, error) {
+	element, err := njson.LoadsValue(source)
+	if err != nil {
+		return nil, err
+	}
+	return Compile(element)
+}
+
+func CompileFile(fpath string) (*Schema, error) {
+	element, err := njson.LoadValue(fpath)
+	if err != nil {
+		return nil, err
+	}
+	return Compile(element)
+}
@@ -0,0 +1,85 @@
+package schema
+
+import (
+	"testing"
+
+	"njson"
+)
+
+// enum, const and uniqueItems compare numbers by value, whatever the mode the instance was read in
+func TestNumberEqualityUseNumber(t *testing.T) {
+	s, err := CompileString(`{"enum": [8.95, 1], "const": 8.95}`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	unique, err := CompileString(`{"uniqueItems": true}`)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, opts := range []*njson.ParseOptions{nil, {UseNumber: true}} {
+		instance, err := opts.LoadsValue(`8.95`)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := s.Validate(instance); err != nil {
+			t.Errorf("UseNumber %v: %v", opts != nil, err)
+		}
+
+		other, _ := opts.LoadsValue(`8.950`)
+		if err := s.Validate(other); err != nil {
+			t.Errorf("UseNumber %v, 8.950: %v", opts != nil, err)
+		}
+
+		items, _ := opts.LoadsValue(`[8.95, 1, 8.950]`)
+		if err := unique.Validate(items); err == nil {
+			t.Errorf("UseNumber %v: 8.95 and 8.950 are not unique", opts != nil)
+		}
+	}
+
+	wrong, _ := (&njson.ParseOptions{UseNumber: true}).LoadsValue(`8.96`)
+	verr, ok := s.Validate(wrong).(*ValidationError)
+	if !ok || len(verr.Violations) != 2 {
+		t.Errorf("8.96 should break enum and const: %v", verr)
+	}
+}
+
+func TestViolationPaths(t *testing.T) {
+	s, err := CompileString(`{
+  "$defs": {"port": {"type": "integer", "minimum": 1, "maximum": 65535}},
+  "type": "object",
+  "required": ["name"],
+  "properties": {"port": {"$ref": "#/$defs/port"}},
+  "additionalProperties": false
+}`)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	instance, err := njson.LoadsValue("{\n  \"port\": 70000,\n  \"extra\": 1\n}")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	verr, ok := s.Validate(instance).(*ValidationError)
+	if !ok {
+		t.Fatal("expected a *ValidationError")
+	}
+
+	want := []Violation{
+		{InstancePath: "", SchemaPath: "/required"},
+		{InstancePath: "/port", SchemaPath: "/$defs/port/maximum"},
+		{InstancePath: "/extra", SchemaPath: "/additionalProperties"},
+	}
+	if len(verr.Violations) != len(want) {
+		t.Fatalf("got %v", verr)
+	}
+	for i, v := range verr.Violations {
+		if v.InstancePath != want[i].InstancePath || v.SchemaPath != want[i].SchemaPath {
+			t.Errorf("violation %d = %q %q, want %q %q", i, v.InstancePath, v.SchemaPath, want[i].InstancePath, want[i].SchemaPath)
+		}
+	}
+	if line := verr.Violations[1].Span.Start.Line; line != 2 {
+		t.Errorf("port violation on line %d, want 2", line)
+	}
+}
@@ -0,0 +1,485 @@
+package schema
+
+import (
+	"fmt"
+	"math/big"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"njson"
+)
+
+/*
+ * Violation is an instance that does not follow the schema. InstancePath
+ * and SchemaPath are JSON Pointers, Span is where the instance was read
+ * from, it is not valid for elements that were not parsed.
+ */
+type Violation struct {
+	InstancePath string
+	SchemaPath   string
+	Message      string
+	Span         njson.Span
+}
+
+func (self Violation) String() string {
+	path := self.InstancePath
+	if path == "" {
+		path = "(root)"
+	}
+
+	s := path + ": " + self.Message
+	if self.Span.IsValid() {
+		s += fmt.Sprintf(" (line %d column %d)", self.Span.Start.Line, self.Span.Start.Column)
+	}
+	return s
+}
+
+// ValidationError lists every violation found by Validate.
+type ValidationError struct {
+	Violations []Violation
+}
+
+func (self *ValidationError) Error() string {
+	lines := []string{}
+	for _, v := range self.Violations {
+		lines = append(lines, v.String())
+	}
+	return strings.Join(lines, "\n")
+}
+
+// maxRefDepth stops a $ref loop that never goes into the instance, like {"$ref": "#"}
+const maxRefDepth = 512
+
+type validator struct {
+	violations []Violation
+	depth      int
+}
+
+func (self *validator) add(n *node, keyword string, instance njson.JsonElement, path njson.Pointer, format string, a ...interface{}) {
+	span, _ := njson.SpanOf(instance)
+
+	self.violations = append(self.violations, Violation{
+		InstancePath: path.String(),
+		SchemaPath:   n.path + "/" + keyword,
+		Message:      fmt.Sprintf(format, a...),
+		Span:         span,
+	})
+}
+
+// Validate returns a *ValidationError when instance does not follow the schema.
+func (self *Schema) Validate(instance njson.JsonElement) error {
+	v := &validator{}
+	self.root.validate(v, instance, njson.Pointer{})
+
+	if len(v.violations) > 0 {
+		return &ValidationError{Violations: v.violations}
+	}
+	return nil
+}
+
+func typeName(e njson.JsonElement) string {
+	switch e.Type() {
+	case njson.ELE_NULL:
+		return "null"
+	case njson.ELE_BOOL:
+		return "boolean"
+	case njson.ELE_DICT:
+		return "object"
+	case njson.ELE_ARRAY:
+		return "array"
+	case njson.ELE_STRING:
+		return "string"
+	case njson.ELE_INTEGER:
+		return "integer"
+	}
+	return "number"
+}
+
+func hasType(e njson.JsonElement, t string) bool {
+	actual := typeName(e)
+
+	switch t {
+	case actual:
+		return true
+	case "number":
+		return actual == "integer"
+	case "integer":
+		// 1.0 is an integer
+		r, ok := numberRat(e)
+		return actual == "number" && ok && r.IsInt()
+	}
+	return false
+}
+
+func isNumber(e njson.JsonElement) bool {
+	return e.Type() == njson.ELE_INTEGER || e.Type() == njson.ELE_FLOAT
+}
+
+// decimal is implemented by the integer and float elements.
+type decimal interface {
+	ToDecimalString() string
+}
+
+// numberRat returns the exact value of a number element.
+func numberRat(e njson.JsonElement) (*big.Rat, bool) {
+	if !isNumber(e) {
+		return nil, false
+	}
+
+	if d, ok := e.(decimal); ok {
+		return new(big.Rat).SetString(d.ToDecimalString())
+	}
+
+	if e.Type() == njson.ELE_INTEGER {
+		return new(big.Rat).SetInt64(e.ToInteger64()), true
+	}
+	return new(big.Rat).SetString(strconv.FormatFloat(e.ToFloat64(), 'g', -1, 64))
+}
+
+// equal compares two values as JSON Schema does: 1 and 1.0 are equal, the order of keys is ignored.
+func equal(a, b njson.JsonElement) bool {
+	if isNumber(a) && isNumber(b) {
+		x, ok1 := numberRat(a)
+		y, ok2 := numberRat(b)
+		return ok1 && ok2 && x.Cmp(y) == 0
+	}
+
+	if a.Type() != b.Type() {
+		return false
+	}
+
+	switch a.Type() {
+	case njson.ELE_STRING:
+		return a.ToString() == b.ToString()
+	case njson.ELE_BOOL:
+		return a.ToBool() == b.ToBool()
+	case njson.ELE_NULL:
+		return true
+
+	case njson.ELE_ARRAY:
+		x, y := a.ToElementArray(), b.ToElementArray()
+		if len(x) != len(y) {
+			return false
+		}
+		for i := range x {
+			if !equal(x[i], y[i]) {
+				return false
+			}
+		}
+		return true
+
+	case njson.ELE_DICT:
+		x, y := a.ToDict(), b.ToDict()
+		if len(x) != len(y) {
+			return false
+		}
+		for k, v := range x {
+			w, ok := y[k]
+			if !ok || !equal(v, w) {
+				return false
+			}
+		}
+		return true
+	}
+
+	return false
+}
+
+func brief(e njson.JsonElement) string {
+	s, err := njson.Dumps(e)
+	if err != nil {
+		s = e.String()
+	}
+	if len(s) > 40 {
+		s = s[:37] + "..."
+	}
+	return s
+}
+
+func ratString(r *big.Rat) string {
+	if r.IsInt() {
+		return r.Num().String()
+	}
+	return r.FloatString(10)
+}
+
+// valid reports whether instance follows n, the violations are not kept.
+func (self *node) valid(v *validator, instance njson.JsonElement, path njson.Pointer) bool {
+	sub := &validator{depth: v.depth}
+	self.validate(sub, instance, path)
+	return len(sub.violations) == 0
+}
+
+func (self *node) validate(v *validator, instance njson.JsonElement, path njson.Pointer) {
+	if self.always != nil {
+		if !*self.always {
+			v.add(self, "", instance, path, "no value is allowed here")
+			v.violations[len(v.violations)-1].SchemaPath = self.path
+		}
+		return
+	}
+
+	if self.ref != nil {
+		if v.depth >= maxRefDepth {
+			v.add(self, "$ref", instance, path, "$ref nested too deep")
+		} else {
+			v.depth++
+			self.ref.validate(v, instance, path)
+			v.depth--
+		}
+	}
+
+	if len(self.types) > 0 {
+		ok := false
+		for _, t := range self.types {
+			if hasType(instance, t) {
+				ok = true
+				break
+			}
+		}
+		if !ok {
+			v.add(self, "type", instance, path, "expected %s, got %s", strings.Join(self.types, " or "), typeName(instance))
+		}
+	}
+
+	if self.enum != nil {
+		ok := false
+		for _, item := range self.enum {
+			if equal(instance, item) {
+				ok = true
+				break
+			}
+		}
+		if !ok {
+			v.add(self, "enum", instance, path, "value %s is not one of the allowed values", brief(instance))
+		}
+	}
+
+	if self.konst != nil && !equal(instance, self.konst) {
+		v.add(self, "const", instance, path, "value %s is not %s", brief(instance), brief(self.konst))
+	}
+
+	if self.format != "" {
+		if check, ok := formats[self.format]; ok && instance.Type() == njson.ELE_STRING && !check(instance.ToString()) {
+			v.add(self, "format", instance, path, "%q is not a valid %s", instance.ToString(), self.format)
+		}
+	}
+
+	switch {
+	case isNumber(instance):
+		self.validateNumber(v, instance, path)
+	case instance.Type() == njson.ELE_STRING:
+		self.validateString(v, instance, path)
+	case instance.Type() == njson.ELE_ARRAY:
+		self.validateArray(v, instance, path)
+	case instance.Type() == njson.ELE_DICT:
+		self.validateObject(v, instance, path)
+	}
+
+	self.validateApplicators(v, instance, path)
+}
+
+func (self *node) validateNumber(v *validator, instance njson.JsonElement, path njson.Pointer) {
+	x, ok := numberRat(instance)
+	if !ok {
+		// NaN and Infinity read as JSON5
+		return
+	}
+	value := brief(instance)
+
+	if self.minimum != nil && x.Cmp(self.minimum) < 0 {
+		v.add(self, "minimum", instance, path, "value %s is less than minimum %s", value, ratString(self.minimum))
+	}
+	if self.maximum != nil && x.Cmp(self.maximum) > 0 {
+		v.add(self, "maximum", instance, path, "value %s is greater than maximum %s", value, ratString(self.maximum))
+	}
+	if self.exclusiveMinimum != nil && x.Cmp(self.exclusiveMinimum) <= 0 {
+		v.add(self, "exclusiveMinimum", instance, path, "value %s is not greater than %s", value, ratString(self.exclusiveMinimum))
+	}
+	if self.exclusiveMaximum != nil && x.Cmp(self.exclusiveMaximum) >= 0 {
+		v.add(self, "exclusiveMaximum", instance, path, "value %s is not less than %s", value, ratString(self.exclusiveMaximum))
+	}
+	if self.multipleOf != nil && !new(big.Rat).Quo(x, self.multipleOf).IsInt() {
+		v.add(self, "multipleOf", instance, path, "value %s is not a multiple of %s", value, ratString(self.multipleOf))
+	}
+}
+
+func (self *node) validateString(v *validator, instance njson.JsonElement, path njson.Pointer) {
+	s := instance.ToString()
+	length := utf8.RuneCountInString(s)
+
+	if self.minLength != nil && length < *self.minLength {
+		v.add(self, "minLength", instance, path, "string is shorter than %d characters", *self.minLength)
+	}
+	if self.maxLength != nil && length > *self.maxLength {
+		v.add(self, "maxLength", instance, path, "string is longer than %d characters", *self.maxLength)
+	}
+	if self.pattern != nil && !self.pattern.MatchString(s) {
+		v.add(self, "pattern", instance, path, "string does not match pattern %q", self.pattern.String())
+	}
+}
+
+func (self *node) validateArray(v *validator, instance njson.JsonElement, path njson.Pointer) {
+	items := instance.ToElementArray()
+
+	if self.minItems != nil && len(items) < *self.minItems {
+		v.add(self, "minItems", instance, path, "array has fewer than %d items", *self.minItems)
+	}
+	if self.maxItems != nil && len(items) > *self.maxItems {
+		v.add(self, "maxItems", instance, path, "array has more than %d items", *self.maxItems)
+	}
+
+	if self.uniqueItems {
+	unique:
+		for i := range items {
+			for j := 0; j < i; j++ {
+				if equal(items[i], items[j]) {
+					v.add(self, "uniqueItems", instance, path, "items %d and %d are equal", j, i)
+					break unique
+				}
+			}
+		}
+	}
+
+	for i, item := range items {
+		itemPath := path.Child(strconv.Itoa(i))
+
+		if i < len(self.prefixItems) {
+			self.prefixItems[i].validate(v, item, itemPath)
+		} else if self.items != nil {
+			self.items.validate(v, item, itemPath)
+		}
+	}
+
+	if self.contains != nil {
+		matches := 0
+		for i, item := range items {
+			if self.contains.valid(v, item, path.Child(strconv.Itoa(i))) {
+				matches++
+			}
+		}
+
+		min := 1
+		if self.minContains != nil {
+			min = *self.minContains
+		}
+		if matches < min {
+			v.add(self, "contains", instance, path, "array has %d items matching contains, at least %d are required", matches, min)
+		}
+		if self.maxContains != nil && matches > *self.maxContains {
+			v.add(self, "maxContains", instance, path, "array has %d items matching contains, at most %d are allowed", matches, *self.maxContains)
+		}
+	}
+}
+
+func (self *node) validateObject(v *validator, instance njson.JsonElement, path njson.Pointer) {
+	members := instance.ToDict()
+	keys := dictKeys(instance)
+
+	if self.minProperties != nil && len(members) < *self.minProperties {
+		v.add(self, "minProperties", instance, path, "object has fewer than %d properties", *self.minProperties)
+	}
+	if self.maxProperties != nil && len(members) > *self.maxProperties {
+		v.add(self, "maxProperties", instance, path, "object has more than %d properties", *self.maxProperties)
+	}
+
+	for _, name := range self.required {
+		if _, ok := members[name]; !ok {
+			v.add(self, "required", instance, path, "missing required property %q", name)
+		}
+	}
+
+	for _, name := range sortedKeys(self.dependentRequired) {
+		if _, ok := members[name]; !ok {
+			continue
+		}
+		for _, dep := range self.dependentRequired[name] {
+			if _, ok := members[dep]; !ok {
+				v.add(self, "dependentRequired", instance, path, "property %q is required when %q is present", dep, name)
+			}
+		}
+	}
+
+	for _, name := range keys {
+		value := members[name]
+		memberPath := path.Child(name)
+		matched := false
+
+		if sub, ok := self.properties[name]; ok {
+			sub.validate(v, value, memberPath)
+			matched = true
+		}
+
+		for _, p := range self.patternProperties {
+			if p.re.MatchString(name) {
+				p.schema.validate(v, value, memberPath)
+				matched = true
+			}
+		}
+
+		if !matched && self.additionalProperties != nil {
+			if self.additionalProperties.always != nil && !*self.additionalProperties.always {
+				v.add(self, "additionalProperties", value, memberPath, "property %q is not allowed", name)
+			} else {
+				self.additionalProperties.validate(v, value, memberPath)
+			}
+		}
+
+		if self.propertyNames != nil && !self.propertyNames.valid(v, njson.NewJsonStringElement(name), memberPath) {
+			v.add(self, "propertyNames", value, memberPath, "property name %q is not valid", name)
+		}
+
+		if sub, ok := self.dependentSchemas[name]; ok {
+			sub.validate(v, instance, path)
+		}
+	}
+}
+
+func (self *node) validateApplicators(v *validator, instance njson.JsonElement, path njson.Pointer) {
+	for _, sub := range self.allOf {
+		sub.validate(v, instance, path)
+	}
+
+	if len(self.anyOf) > 0 {
+		ok := false
+		for _, sub := range self.anyOf {
+			if sub.valid(v, instance, path) {
+				ok = true
+				break
+			}
+		}
+		if !ok {
+			v.add(self, "anyOf", instance, path, "value does not match any schema of anyOf")
+		}
+	}
+
+	if len(self.oneOf) > 0 {
+		matches := []string{}
+		for i, sub := range self.oneOf {
+			if sub.valid(v, instance, path) {
+				matches = append(matches, strconv.Itoa(i))
+			}
+		}
+		switch {
+		case len(matches) == 0:
+			v.add(self, "oneOf", instance, path, "value does not match any schema of oneOf")
+		case len(matches) > 1:
+			v.add(self, "oneOf", instance, path, "value matches schemas %s of oneOf, only one is allowed", strings.Join(matches, ", "))
+		}
+	}
+
+	if self.not != nil && self.not.valid(v, instance, path) {
+		v.add(self, "not", instance, path, "value must not match the schema of not")
+	}
+
+	if self.ifNode != nil {
+		if self.ifNode.valid(v, instance, path) {
+			if self.thenNode != nil {
+				self.thenNode.validate(v, instance, path)
+			}
+		} else if self.elseNode != nil {
+			self.elseNode.validate(v, instance, path)
+		}
+	}
+}
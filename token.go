@@ -10,6 +10,9 @@ type token struct {
 	tokenType int
 	lineno    int
 	offset    int
+
+	comments []*comment // comments read before the token
+	span     Span
 }
 
 func (self *token) String() string {
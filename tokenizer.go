@@ -2,69 +2,160 @@ package njson
 
 import (
 	"fmt"
+	"io"
 	"io/ioutil"
-	"strconv"
+	"math/big"
+	"os"
 	"unicode"
+	"unicode/utf16"
+	"unicode/utf8"
 )
 
 type tokenizer struct {
-	source    []byte
+	buf       *jsonBuffer
 	filepath  string
-	_cp       int
 	_ln       int
 	_ofs      int
 	jpathMode bool
+	opts      *ParseOptions
+
+	// position of the token being read
+	_tln  int
+	_tofs int
+	_tpos int
+
+	// comments read since the last token
+	comments []*comment
 }
 
 func (self *tokenizer) peek(step int) (byte, bool) {
-	if len(self.source) > self._cp+step {
-		return self.source[self._cp+step], true
-	}
-	return 0, false
+	return self.buf.peek(step)
 }
 
+// moveCp consumes step bytes and keeps the line and column up to date.
 func (self *tokenizer) moveCp(step int) {
-	self._cp += step
-}
+	for i := 0; i < step; i++ {
+		ch, ok := self.buf.peek(0)
+		if !ok {
+			return
+		}
 
-func (self *tokenizer) readNext() (byte, bool) {
-	self._cp++
-	if len(self.source) > self._cp {
-		return self.source[self._cp], true
+		if ch == '\n' {
+			self._ln++
+			self._ofs = 1
+		} else {
+			self._ofs++
+		}
+		self.buf.skip(1)
 	}
-	return 0, false
 }
 
 func (self *tokenizer) peekString(size int) string {
-	sb := make([]byte, size)
+	return self.buf.peekString(size)
+}
 
-	for i := 0; i+self._cp < len(self.source) && i < size; i++ {
-		sb[i] = self.source[self._cp+i]
+// peekChar returns the character at the current position for error messages.
+func (self *tokenizer) peekChar() string {
+	s := self.peekString(utf8.UTFMax)
+	r, _ := utf8.DecodeRuneInString(s)
+
+	switch {
+	case s == "":
+		return "end of input"
+	case r == utf8.RuneError:
+		return fmt.Sprintf("byte 0x%02x", s[0])
+	case !unicode.IsPrint(r):
+		return fmt.Sprintf("%U", r)
 	}
+	return string(r)
+}
 
-	return string(sb)
+func (self *tokenizer) markToken() {
+	self._tln = self._ln
+	self._tofs = self._ofs
+	self._tpos = self.buf.offset()
 }
 
 func (self *tokenizer) makeToken(value string, type_ int) *token {
-	return &token{
-		lineno:    self._ln,
-		offset:    self._ofs,
+	tok := &token{
+		lineno:    self._tln,
+		offset:    self._tofs,
 		tokenType: type_,
 		value:     string(value),
+		comments:  self.comments,
+		span: Span{
+			Start: Position{Line: self._tln, Column: self._tofs, Offset: self._tpos},
+			End:   self.pos(),
+		},
 	}
+	self.comments = nil
+
+	return tok
+}
+
+func hexValue(ch byte) (rune, bool) {
+	switch {
+	case ch >= '0' && ch <= '9':
+		return rune(ch - '0'), true
+	case ch >= 'a' && ch <= 'f':
+		return rune(ch-'a') + 10, true
+	case ch >= 'A' && ch <= 'F':
+		return rune(ch-'A') + 10, true
+	}
+	return 0, false
 }
 
-func (self *tokenizer) getEscape() ([]byte, int, error) {
-	cur := &self._cp
-	nxtcp := *cur + 1
+// peekHex4 reads the 4 hex digits at step bytes after the current position.
+func (self *tokenizer) peekHex4(step int) (rune, bool) {
+	var r rune
 
-	if nxtcp >= len(self.source) {
-		return nil, 0, fmt.Errorf("invalid escape character")
+	for i := 0; i < 4; i++ {
+		ch, ok := self.peek(step + i)
+		if !ok {
+			return 0, false
+		}
+
+		v, ok := hexValue(ch)
+		if !ok {
+			return 0, false
+		}
+		r = r<<4 | v
 	}
 
-	nxtch := self.source[nxtcp]
+	return r, true
+}
 
-	var hexString string
+// encodeWTF8 encodes a surrogate code point the way utf-8 would encode it if it were allowed.
+func encodeWTF8(r rune) []byte {
+	return []byte{
+		0xe0 | byte(r>>12),
+		0x80 | byte(r>>6)&0x3f,
+		0x80 | byte(r)&0x3f,
+	}
+}
+
+func (self *tokenizer) loneSurrogate(r rune) ([]byte, error) {
+	switch self.opts.LoneSurrogates {
+
+	case SurrogateReplace:
+		return []byte(string(utf8.RuneError)), nil
+	case SurrogateWTF8:
+		return encodeWTF8(r), nil
+	}
+
+	return nil, errorf(ErrInvalidString, "lone surrogate \\u%04x in string", r)
+}
+
+/*
+ * getEscape decodes the escape sequence at the current position, it returns
+ * the decoded bytes and how many bytes after the backslash the sequence takes.
+ */
+func (self *tokenizer) getEscape(quote byte) ([]byte, int, error) {
+	nxtch, ok := self.peek(1)
+
+	if !ok {
+		return nil, 0, errorf(ErrUnexpectedEOF, "unterminated string")
+	}
 
 	switch nxtch {
 
@@ -72,187 +163,762 @@ func (self *tokenizer) getEscape() ([]byte, int, error) {
 		return []byte{'\n'}, 1, nil
 	case 't':
 		return []byte{'\t'}, 1, nil
-	case '"':
-		return []byte{'"'}, 1, nil
+	case 'r':
+		return []byte{'\r'}, 1, nil
+	case 'b':
+		return []byte{'\b'}, 1, nil
+	case 'f':
+		return []byte{'\f'}, 1, nil
+	case '\\':
+		return []byte{'\\'}, 1, nil
+	case quote:
+		return []byte{quote}, 1, nil
 	case '/':
 		return []byte{'/'}, 1, nil
 	case 'u': // unicode
-		hexString = self.peekString(6)[2:]
-		u32, err := strconv.ParseUint(hexString, 16, 32)
-		if err != nil {
-			return nil, 0, err
+		r, ok := self.peekHex4(2)
+		if !ok {
+			return nil, 0, errorf(ErrInvalidEscape, "invalid unicode escape, except 4 hex digits after \\u")
+		}
+
+		if !utf16.IsSurrogate(r) {
+			return []byte(string(r)), 5, nil
 		}
 
-		return []byte(string([]rune{rune(u32)})), 5, nil
+		// a high surrogate followed by a low one is a single character
+		if next := self.peekString(8); r < 0xdc00 && len(next) == 8 && next[6:] == "\\u" {
+			if r2, ok := self.peekHex4(8); ok && r2 >= 0xdc00 && r2 <= 0xdfff {
+				return []byte(string(utf16.DecodeRune(r, r2))), 11, nil
+			}
+		}
+
+		b, err := self.loneSurrogate(r)
+		return b, 5, err
 	}
 
-	return nil, 0, fmt.Errorf("invalid escape character : '" + string(nxtch) + "'")
+	if self.opts.JSON5 {
+		return self.getJSON5Escape(nxtch)
+	}
+
+	return nil, 0, errorf(ErrInvalidEscape, "invalid escape character : '%c'", nxtch)
 }
 
-func (self *tokenizer) parseString() (string, error) {
-	cur := &self._cp
+// getJSON5Escape decodes the escapes JSON5 adds to the json ones.
+func (self *tokenizer) getJSON5Escape(nxtch byte) ([]byte, int, error) {
+	switch nxtch {
+
+	case 'v':
+		return []byte{'\v'}, 1, nil
+	case '0':
+		if ch, _ := self.peek(2); isDigit(ch) {
+			return nil, 0, errorf(ErrInvalidEscape, "invalid escape character : '\\0' followed by a digit")
+		}
+		return []byte{0}, 1, nil
+	case 'x':
+		hi, ok1 := self.peek(2)
+		lo, ok2 := self.peek(3)
+		h, ok3 := hexValue(hi)
+		l, ok4 := hexValue(lo)
+		if !(ok1 && ok2 && ok3 && ok4) {
+			return nil, 0, errorf(ErrInvalidEscape, "invalid hex escape, except 2 hex digits after \\x")
+		}
+		return []byte(string(h<<4 | l)), 3, nil
+
+	// line continuations
+	case '\n':
+		return nil, 1, nil
+	case '\r':
+		if ch, _ := self.peek(2); ch == '\n' {
+			return nil, 2, nil
+		}
+		return nil, 1, nil
+	}
+
+	if s := self.peekString(4); len(s) == 4 && (s[1:] == "\u2028" || s[1:] == "\u2029") {
+		return nil, 3, nil
+	}
+
+	if isDigit(nxtch) {
+		return nil, 0, errorf(ErrInvalidEscape, "invalid escape character : '%c'", nxtch)
+	}
+
+	// any other character stands for itself
+	return []byte{nxtch}, 1, nil
+}
+
+func (self *tokenizer) parseString(quote byte) (string, error) {
 	buf := []byte{}
 
-	self.moveCp(1) // eat "
-	var ch byte
+	self.moveCp(1) // eat the quote
 
-	for ; *cur < len(self.source); *cur++ {
-		ch = self.source[*cur]
+	for {
+		ch, ok := self.peek(0)
+		if !ok {
+			return "", errorf(ErrUnexpectedEOF, "unterminated string")
+		}
 
 		switch ch {
 
 		case '\n':
-			return "", fmt.Errorf("do not support muitline string")
+			if self.opts.JSON5 {
+				return "", errorf(ErrInvalidString, "newline in string, end the line with '\\' to continue the string")
+			}
+			return "", errorf(ErrInvalidString, "multiline strings are not supported, use \\n")
 		case '\\':
-			ech, jump, err := self.getEscape()
+			ech, jump, err := self.getEscape(quote)
 
 			if err != nil {
-				self.handleError(err)
+				return "", err
 			}
 
 			buf = append(buf, ech...)
+			if max := self.opts.MaxStringLength; max > 0 && len(buf) > max {
+				return "", errorf(ErrStringLimit, "string is longer than %d bytes", max)
+			}
 
-			*cur += jump
+			self.moveCp(jump + 1)
 			continue
 
-		case '"':
-			// *cur++
-			goto outside
+		case quote:
+			self.moveCp(1)
+			return string(buf), nil
 		}
 
-		buf = append(buf, self.source[*cur])
+		if max := self.opts.MaxStringLength; max > 0 && len(buf) >= max {
+			return "", errorf(ErrStringLimit, "string is longer than %d bytes", max)
+		}
+
+		// JSON5 only forbids line terminators, a tab may be written as it is
+		if ch == '\r' || (ch < 0x20 && !self.opts.JSON5) {
+			return "", errorf(ErrInvalidString, "control character 0x%02x in string must be escaped", ch)
+		}
+
+		buf = append(buf, ch)
+		self.moveCp(1)
 	}
+}
 
-outside:
+func isDigit(ch byte) bool {
+	return ch >= '0' && ch <= '9'
+}
 
-	return string(buf), nil
+// readDigits appends the digits at the current position to buf, start is the offset of the number.
+func (self *tokenizer) readDigits(buf []byte, start int) ([]byte, error) {
+	for ch, ok := self.peek(0); ok && isDigit(ch); ch, ok = self.peek(0) {
+		if err := self.checkNumberLength(start); err != nil {
+			return nil, err
+		}
+		buf = append(buf, ch)
+		self.moveCp(1)
+	}
+	return buf, nil
 }
 
-func (self *tokenizer) parseNumber() (string, int) {
-	cur := &self._cp
+/*
+ * parseNumber reads a number as RFC 8259 defines it:
+ *
+ *	number = [ "-" ] int [ frac ] [ exp ]
+ *	int    = "0" / ( digit1-9 *DIGIT )
+ *	frac   = "." 1*DIGIT
+ *	exp    = ( "e" / "E" ) [ "-" / "+" ] 1*DIGIT
+ *
+ * the token is a _T_FLOAT when it has a fraction or an exponent. JSON5
+ * numbers are turned into json ones: +1 is 1, 0x1f is 31, .5 is 0.5 and
+ * 5. is 5.0.
+ */
+func (self *tokenizer) parseNumber() (string, int, error) {
 	buf := []byte{}
-
-	hasDot := false
 	tokType := _T_INTEGER
+	json5 := self.opts.JSON5
+	start := self.buf.offset()
+
+	sign, _ := self.peek(0)
+	if sign == '-' || (json5 && sign == '+') {
+		if sign == '-' {
+			buf = append(buf, '-')
+		}
+		self.moveCp(1)
+	} else {
+		sign = 0
+	}
+
+	ch, ok := self.peek(0)
+
+	if json5 {
+		if ok && isIdentStart(ch) {
+			return self.parseNamedNumber(buf)
+		}
+
+		if nxt, _ := self.peek(1); ch == '0' && (nxt == 'x' || nxt == 'X') {
+			return self.parseHexNumber(buf, start)
+		}
+	}
+
+	leadingDot := json5 && ch == '.'
+
+	if leadingDot {
+		buf = append(buf, '0')
+
+	} else if !ok || !isDigit(ch) {
+		if sign == 0 {
+			return "", 0, errorf(ErrInvalidNumber, "invalid number, except digit")
+		}
+		return "", 0, errorf(ErrInvalidNumber, "invalid number, except digit after '%c'", sign)
+
+	} else if ch == '0' {
+		if err := self.checkNumberLength(start); err != nil {
+			return "", 0, err
+		}
+		buf = append(buf, '0')
+		self.moveCp(1)
+
+		if ch, ok := self.peek(0); ok && isDigit(ch) {
+			return "", 0, errorf(ErrInvalidNumber, "invalid number, leading zeros are not allowed")
+		}
+	} else {
+		var err error
+		if buf, err = self.readDigits(buf, start); err != nil {
+			return "", 0, err
+		}
+	}
+
+	if ch, _ := self.peek(0); ch == '.' {
+		if err := self.checkNumberLength(start); err != nil {
+			return "", 0, err
+		}
+		tokType = _T_FLOAT
+		buf = append(buf, '.')
+		self.moveCp(1)
+
+		if ch, ok := self.peek(0); !ok || !isDigit(ch) {
+			if !json5 || leadingDot {
+				return "", 0, errorf(ErrInvalidNumber, "invalid number, except digit after '.'")
+			}
+			buf = append(buf, '0')
+		}
+
+		var err error
+		if buf, err = self.readDigits(buf, start); err != nil {
+			return "", 0, err
+		}
+	}
+
+	if ch, _ := self.peek(0); ch == 'e' || ch == 'E' {
+		if err := self.checkNumberLength(start); err != nil {
+			return "", 0, err
+		}
+		tokType = _T_FLOAT
+		buf = append(buf, ch)
+		self.moveCp(1)
+
+		if ch, _ := self.peek(0); ch == '+' || ch == '-' {
+			if err := self.checkNumberLength(start); err != nil {
+				return "", 0, err
+			}
+			buf = append(buf, ch)
+			self.moveCp(1)
+		}
+
+		if ch, ok := self.peek(0); !ok || !isDigit(ch) {
+			return "", 0, errorf(ErrInvalidNumber, "invalid number, except digit in exponent")
+		}
+
+		var err error
+		if buf, err = self.readDigits(buf, start); err != nil {
+			return "", 0, err
+		}
+	}
 
-	var ch byte
+	return string(buf), tokType, nil
+}
 
-	if self.source[*cur] == '-' {
-		buf = append(buf, '-')
-		*cur++
+/*
+ * checkNumberLength fails when the number starting at offset start already
+ * has MaxNumberLength bytes, it is called before each byte is read so a
+ * long number is cut where it crosses the limit.
+ */
+func (self *tokenizer) checkNumberLength(start int) error {
+	if max := self.opts.MaxNumberLength; max > 0 && self.buf.offset()-start >= max {
+		return errorf(ErrNumberLimit, "number is longer than %d bytes", max)
 	}
+	return nil
+}
+
+// parseNamedNumber reads Infinity or NaN after an optional sign.
+func (self *tokenizer) parseNamedNumber(sign []byte) (string, int, error) {
+	name, err := self.parseIdent()
+	if err != nil {
+		return "", 0, err
+	}
+
+	switch name {
+	case "Infinity":
+		return string(sign) + name, _T_FLOAT, nil
+	case "NaN":
+		return name, _T_FLOAT, nil
+	}
+
+	return "", 0, errorf(ErrInvalidNumber, "invalid number : %s", name)
+}
 
-	for ; *cur < len(self.source); *cur++ {
-		ch = self.source[*cur]
+// parseHexNumber reads a JSON5 hex integer, the token holds its decimal value.
+func (self *tokenizer) parseHexNumber(sign []byte, start int) (string, int, error) {
+	for i := 0; i < 2; i++ { // eat '0x'
+		if err := self.checkNumberLength(start); err != nil {
+			return "", 0, err
+		}
+		self.moveCp(1)
+	}
 
-		if ch == '.' && !hasDot {
-			hasDot = true
-			tokType = _T_FLOAT
-		} else if !unicode.IsNumber(rune(ch)) {
+	digits := []byte{}
+	for ch, ok := self.peek(0); ok; ch, ok = self.peek(0) {
+		if _, isHex := hexValue(ch); !isHex {
 			break
 		}
+		if err := self.checkNumberLength(start); err != nil {
+			return "", 0, err
+		}
+		digits = append(digits, ch)
+		self.moveCp(1)
+	}
+
+	if len(digits) == 0 {
+		return "", 0, errorf(ErrInvalidNumber, "invalid number, except hex digit after '0x'")
+	}
+
+	n, _ := new(big.Int).SetString(string(digits), 16)
+	if len(sign) > 0 {
+		n.Neg(n)
+	}
 
+	return n.String(), _T_INTEGER, nil
+}
+
+func isIdentStart(ch byte) bool {
+	return isNameStart(ch) || ch == '$'
+}
+
+func isIdentChar(ch byte) bool {
+	return isIdentStart(ch) || isDigit(ch)
+}
+
+// parseIdent reads a JSON5 identifier (an unquoted key, a keyword, Infinity or NaN).
+func (self *tokenizer) parseIdent() (string, error) {
+	buf := []byte{}
+
+	for ch, ok := self.peek(0); ok && isIdentChar(ch); ch, ok = self.peek(0) {
+		if max := self.opts.MaxStringLength; max > 0 && len(buf) >= max {
+			return "", errorf(ErrStringLimit, "identifier is longer than %d bytes", max)
+		}
 		buf = append(buf, ch)
+		self.moveCp(1)
 	}
-	*cur-- // for next token
-	return string(buf), tokType
+
+	return string(buf), nil
+}
+
+var json5Keywords = map[string]int{
+	"true":     _T_TRUE,
+	"false":    _T_FALSE,
+	"null":     _T_NULL,
+	"Infinity": _T_FLOAT,
+	"NaN":      _T_FLOAT,
 }
 
-func (self *tokenizer) handleError(err error) {
-	msg := err.Error()
+// spaceSize returns the size of the JSON5 white space at the current position, 0 if there is none.
+func (self *tokenizer) spaceSize(ch byte) int {
+	switch ch {
+	case ' ', '\t', '\r', '\n':
+		return 1
+	case '\v', '\f':
+		if self.opts.JSON5 {
+			return 1
+		}
+	}
 
-	njerr := &NJsonError{
-		filepath: self.filepath,
-		lno:      self._ln,
-		offset:   self._ofs,
-		message:  msg,
-		source:   self.source,
+	if ch < utf8.RuneSelf || !self.opts.JSON5 {
+		return 0
 	}
 
-	njerr.ThrowError()
+	r, size := utf8.DecodeRuneInString(self.peekString(utf8.UTFMax))
+	if unicode.Is(unicode.Zs, r) || r == 0xfeff || r == 0x2028 || r == 0x2029 {
+		return size
+	}
+	return 0
 }
 
-func (self *tokenizer) run() *tokenStream {
-	self._cp = -1
-	self._ofs = 1
-	self._ln = 1
+// readComment reads a '//' or '/* */' comment, ok is false when there is none.
+func (self *tokenizer) readComment() (string, bool, error) {
+	buf := []byte{}
+
+	switch self.peekString(2) {
 
-	stream := newTokenStream(self.filepath)
-	stream.source = self.source
+	case "//":
+		for ch, ok := self.peek(0); ok && ch != '\n'; ch, ok = self.peek(0) {
+			buf = append(buf, ch)
+			self.moveCp(1)
+		}
+		return string(buf), true, nil
+
+	case "/*":
+		start := self.pos()
+		buf = append(buf, "/*"...)
+		self.moveCp(2)
+
+		for {
+			if self.peekString(2) == "*/" {
+				buf = append(buf, "*/"...)
+				self.moveCp(2)
+				return string(buf), true, nil
+			}
+			ch, ok := self.peek(0)
+			if !ok {
+				return "", false, self.errorAt(errorf(ErrUnexpectedEOF, "unterminated comment"), start)
+			}
+			buf = append(buf, ch)
+			self.moveCp(1)
+		}
+	}
 
-	for ch, ok := self.readNext(); ok; ch, ok = self.readNext() {
-		switch ch {
-		case '\n':
-			self._ofs = 1
-			self._ln++
+	return "", false, nil
+}
+
+// skipSpace eats white space and, when they are allowed, comments which are kept for the next token.
+func (self *tokenizer) skipSpace() error {
+	line := self._ln
+
+	for ch, ok := self.peek(0); ok; ch, ok = self.peek(0) {
+		if size := self.spaceSize(ch); size > 0 {
+			self.moveCp(size)
 			continue
-		case '{':
-			stream.addToken(self.makeToken(string(ch), _T_LLBASKET))
-		case '}':
-			stream.addToken(self.makeToken(string(ch), _T_LRBASKET))
-		case '[':
-			stream.addToken(self.makeToken(string(ch), _T_MLBASKET))
-		case ']':
-			stream.addToken(self.makeToken(string(ch), _T_MRBASKET))
-		case '"':
-			str, err := self.parseString()
-			if err != nil {
-				self.handleError(err)
-			}
-			stream.addToken(self.makeToken(str, _T_STRING))
-			self._ofs += len(str) + 1
-		case ':':
-			stream.addToken(self.makeToken(string(ch), _T_COLON))
-		case ',':
-			stream.addToken(self.makeToken(string(ch), _T_COMMA))
-		case 32, 9, 13:
-			self._ofs++
-		default:
-			if self.peekString(4) == "true" {
-				stream.addToken(self.makeToken("true", _T_TRUE))
-				self.moveCp(3)
-				self._ofs += 3
-
-			} else if self.peekString(5) == "false" {
-				stream.addToken(self.makeToken("false", _T_FALSE))
-				self.moveCp(4)
-				self._ofs += 4
-
-			} else if self.peekString(4) == "null" {
-				stream.addToken(self.makeToken("null", _T_NULL))
-				self.moveCp(3)
-				self._ofs += 3
-
-			} else if unicode.IsNumber(rune(ch)) || ch == '-' {
-				numstr, tokType := self.parseNumber()
-				stream.addToken(self.makeToken(numstr, tokType))
-				self._ofs += len(numstr) - 1
-
-			} else {
-				self.handleError(fmt.Errorf("invalid character : " + string(ch)))
+		}
+
+		if ch != '/' || self.jpathMode || !self.opts.comments() {
+			return nil
+		}
+
+		ln := self._ln
+		text, ok, err := self.readComment()
+		if err != nil {
+			return err
+		}
+		if !ok {
+			return nil
+		}
+
+		self.comments = append(self.comments, &comment{
+			text:     commentText(text),
+			sameLine: ln == line,
+		})
+	}
+
+	return nil
+}
+
+// pos returns the position of the next unread byte.
+func (self *tokenizer) pos() Position {
+	return Position{Line: self._ln, Column: self._ofs, Offset: self.buf.offset()}
+}
+
+func (self *tokenizer) handleError(err error) *NJsonError {
+	return self.errorAt(err, self.pos())
+}
+
+func (self *tokenizer) errorAt(err error, at Position) *NJsonError {
+	// the input was cut, whatever went wrong at its end
+	if self.buf.atLimit() {
+		err = errorf(ErrInputLimit, "input is larger than %d bytes", self.opts.MaxInputBytes)
+		at = self.pos()
+	}
+
+	return &NJsonError{
+		File:       self.filepath,
+		Line:       at.Line,
+		Column:     at.Column,
+		ByteOffset: at.Offset,
+		Code:       codeOf(err, ErrInvalidCharacter),
+		Message:    err.Error(),
+		source:     self.buf.source(),
+	}
+}
+
+// next reads the next token, the last token of the input is _T_EOF.
+func (self *tokenizer) next() (*token, error) {
+	if err := self.skipSpace(); err != nil {
+		return nil, err
+	}
+
+	ch, ok := self.peek(0)
+
+	self.markToken()
+
+	if !ok {
+		if err := self.buf.Err(); err != nil {
+			e := self.handleError(errorf(ErrRead, "read error : %v", err))
+			if e.Code == ErrRead {
+				e.cause = err
 			}
+			return nil, e
+		}
+		return self.makeToken("", _T_EOF), nil
+	}
+
+	if self.jpathMode {
+		if tok, ok, err := self.nextPathToken(ch); ok {
+			return tok, err
+		}
+	}
+
+	switch ch {
+	case '{':
+		self.moveCp(1)
+		return self.makeToken(string(ch), _T_LLBASKET), nil
+	case '}':
+		self.moveCp(1)
+		return self.makeToken(string(ch), _T_LRBASKET), nil
+	case '[':
+		self.moveCp(1)
+		return self.makeToken(string(ch), _T_MLBASKET), nil
+	case ']':
+		self.moveCp(1)
+		return self.makeToken(string(ch), _T_MRBASKET), nil
+	case ':':
+		self.moveCp(1)
+		return self.makeToken(string(ch), _T_COLON), nil
+	case ',':
+		self.moveCp(1)
+		return self.makeToken(string(ch), _T_COMMA), nil
+	case '"':
+		str, err := self.parseString('"')
+		if err != nil {
+			return nil, self.handleError(err)
+		}
+		return self.makeToken(str, _T_STRING), nil
+	}
+
+	if self.opts.JSON5 {
+		if tok, ok, err := self.nextJSON5Token(ch); ok {
+			return tok, err
+		}
+	}
+
+	if self.peekString(4) == "true" {
+		self.moveCp(4)
+		return self.makeToken("true", _T_TRUE), nil
+
+	} else if self.peekString(5) == "false" {
+		self.moveCp(5)
+		return self.makeToken("false", _T_FALSE), nil
+
+	} else if self.peekString(4) == "null" {
+		self.moveCp(4)
+		return self.makeToken("null", _T_NULL), nil
+
+	} else if isDigit(ch) || ch == '-' {
+		numstr, tokType, err := self.parseNumber()
+		if err != nil {
+			return nil, self.handleError(err)
+		}
+		return self.makeToken(numstr, tokType), nil
+	}
+
+	if ch == '/' && !self.jpathMode {
+		return nil, self.handleError(errorf(ErrInvalidCharacter, "invalid character : / (comments are allowed by ParseOptions.AllowComments)"))
+	}
+
+	return nil, self.handleError(errorf(ErrInvalidCharacter, "invalid character : %s", self.peekChar()))
+}
+
+/*
+ * nextJSON5Token reads single quoted strings, identifiers and numbers
+ * starting with '+' or '.', ok is false when ch starts a json token.
+ */
+func (self *tokenizer) nextJSON5Token(ch byte) (*token, bool, error) {
+	switch {
+
+	case ch == '\'':
+		str, err := self.parseString('\'')
+		if err != nil {
+			return nil, true, self.handleError(err)
+		}
+		return self.makeToken(str, _T_STRING), true, nil
+
+	case isIdentStart(ch):
+		name, err := self.parseIdent()
+		if err != nil {
+			return nil, true, self.handleError(err)
+		}
+		if type_, ok := json5Keywords[name]; ok {
+			return self.makeToken(name, type_), true, nil
+		}
+		return self.makeToken(name, _T_NAME), true, nil
+
+	case ch == '+' || ch == '.':
+		numstr, tokType, err := self.parseNumber()
+		if err != nil {
+			return nil, true, self.handleError(err)
+		}
+		return self.makeToken(numstr, tokType), true, nil
+	}
+
+	return nil, false, nil
+}
+
+func isNameStart(ch byte) bool {
+	return ch >= 'a' && ch <= 'z' || ch >= 'A' && ch <= 'Z' || ch == '_' || ch >= 0x80
+}
+
+func isNameChar(ch byte) bool {
+	return isNameStart(ch) || ch >= '0' && ch <= '9'
+}
+
+func (self *tokenizer) parseName() string {
+	buf := []byte{}
+
+	for ch, ok := self.peek(0); ok && isNameChar(ch); ch, ok = self.peek(0) {
+		buf = append(buf, ch)
+		self.moveCp(1)
+	}
+
+	return string(buf)
+}
+
+var pathSingleTokens = map[byte]int{
+	'$': _T_ROOT,
+	'@': _T_CURRENT,
+	'*': _T_STAR,
+	'?': _T_QUESTION,
+	'(': _T_LPAREN,
+	')': _T_RPAREN,
+}
+
+/*
+ * nextPathToken reads the tokens that only appear in JSONPath expressions,
+ * ok is false when ch starts a token shared with json.
+ */
+func (self *tokenizer) nextPathToken(ch byte) (*token, bool, error) {
+	if type_, ok := pathSingleTokens[ch]; ok {
+		self.moveCp(1)
+		return self.makeToken(string(ch), type_), true, nil
+	}
+
+	nxt, _ := self.peek(1)
+
+	switch ch {
+	case '.':
+		value, type_ := ".", _T_DOT
+		if nxt == '.' {
+			value, type_ = "..", _T_DOTDOT
+		}
+		self.moveCp(len(value))
+
+		// no blank space between '.' or '..' and the selector, "$. a" is not a path
+		if ch, ok := self.peek(0); ok && self.spaceSize(ch) > 0 {
+			return nil, true, self.handleError(errorf(ErrInvalidCharacter, "blank space after '%s'", value))
+		}
+		return self.makeToken(value, type_), true, nil
+
+	case '=', '!', '<', '>':
+		if nxt == '=' {
+			self.moveCp(2)
+			return self.makeToken(string(ch)+"=", _T_OP), true, nil
+		}
+		if ch == '=' {
+			return nil, true, self.handleError(errorf(ErrInvalidCharacter, "invalid operator '=', use '=='"))
+		}
+		self.moveCp(1)
+		return self.makeToken(string(ch), _T_OP), true, nil
+
+	case '&', '|':
+		if nxt != ch {
+			return nil, true, self.handleError(errorf(ErrInvalidCharacter, "invalid operator '%c', use '%c%c'", ch, ch, ch))
+		}
+		self.moveCp(2)
+		return self.makeToken(string(ch)+string(ch), _T_OP), true, nil
+
+	case '\'':
+		str, err := self.parseString('\'')
+		if err != nil {
+			return nil, true, self.handleError(err)
+		}
+		return self.makeToken(str, _T_STRING), true, nil
+	}
+
+	if isNameStart(ch) {
+		return self.makeToken(self.parseName(), _T_NAME), true, nil
+	}
+
+	return nil, false, nil
+}
+
+func (self *tokenizer) run() (*tokenStream, error) {
+	stream := newTokenStream(self.filepath)
+
+	for {
+		tok, err := self.next()
+		if err != nil {
+			return nil, err
+		}
+
+		stream.addToken(tok)
+
+		if tok.tokenType == _T_EOF {
+			break
 		}
-		self._ofs++
 	}
 
-	stream.addToken(self.makeToken("", _T_EOF))
+	stream.source = self.buf.source()
 
-	return stream
+	return stream, nil
 }
 
-func (self *tokenizer) RunForTest() *tokenStream {
+func (self *tokenizer) RunForTest() (*tokenStream, error) {
 	return self.run()
 }
 
-func newTokenizer(fpath string) (*tokenizer, error) {
-	b, e := ioutil.ReadFile(fpath)
+func newTokenizerFromBytes(fpath string, source []byte, opts *ParseOptions) *tokenizer {
+	buf := newJsonBufferFromBytes(source)
+	buf.setLimit(opts.MaxInputBytes)
 
-	if e != nil {
-		return nil, e
+	return &tokenizer{
+		buf:      buf,
+		filepath: fpath,
+		opts:     opts,
+		_ln:      1,
+		_ofs:     1,
 	}
+}
+
+func newStreamTokenizer(fpath string, r io.Reader, opts *ParseOptions) *tokenizer {
+	buf := newJsonBuffer(r)
+	buf.setLimit(opts.MaxInputBytes)
 
 	return &tokenizer{
-		source:   b,
+		buf:      buf,
 		filepath: fpath,
-	}, nil
+		opts:     opts,
+		_ln:      1,
+		_ofs:     1,
+	}
+}
+
+func newTokenizer(fpath string, opts *ParseOptions) (*tokenizer, error) {
+	f, e := os.Open(fpath)
+	if e != nil {
+		return nil, e
+	}
+	defer f.Close()
+
+	var r io.Reader = f
+	if opts.MaxInputBytes > 0 {
+		// one byte more tells the file is too large
+		r = io.LimitReader(f, int64(opts.MaxInputBytes)+1)
+	}
+
+	b, e := ioutil.ReadAll(r)
+	if e != nil {
+		return nil, e
+	}
+
+	return newTokenizerFromBytes(fpath, b, opts), nil
 }
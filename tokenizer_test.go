@@ -0,0 +1,44 @@
+package njson
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestStringControlCharacters(t *testing.T) {
+	json5 := &ParseOptions{JSON5: true}
+
+	cases := []struct {
+		opts *ParseOptions
+		src  string
+		want string // "" when the string is rejected
+	}{
+		{nil, "\"a\tb\"", ""},
+		{nil, "\"a\x01b\"", ""},
+		{nil, "\"a\rb\"", ""},
+		{nil, "\"a\nb\"", ""},
+		{json5, "\"a\tb\"", "a\tb"},
+		{json5, "'a\tb'", "a\tb"},
+		{json5, "\"a\x01b\"", "a\x01b"},
+		{json5, "\"a\rb\"", ""},
+		{json5, "\"a\nb\"", ""},
+		{json5, "\"a\\\nb\"", "ab"},
+	}
+
+	for _, c := range cases {
+		element, err := c.opts.LoadsValue(c.src)
+		if c.want == "" {
+			if !errors.Is(err, ErrInvalidString) {
+				t.Errorf("%q: got %v, want %v", c.src, err, ErrInvalidString)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%q: %v", c.src, err)
+			continue
+		}
+		if element.ToString() != c.want {
+			t.Errorf("%q = %q, want %q", c.src, element.ToString(), c.want)
+		}
+	}
+}
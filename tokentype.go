@@ -14,4 +14,17 @@ const (
 	_T_COLON
 	_T_COMMA
 	_T_EOF
+	_T_ERROR
+
+	// JSONPath only, see tokenizer.jpathMode
+	_T_ROOT
+	_T_CURRENT
+	_T_DOT
+	_T_DOTDOT
+	_T_STAR
+	_T_QUESTION
+	_T_LPAREN
+	_T_RPAREN
+	_T_NAME
+	_T_OP
 )
@@ -1,23 +1,77 @@
 package njson
 
+/*
+ * syntax tree of JSONPath expressions (RFC 9535), built by the parser in
+ * jpath mode and evaluated in jsonpath.go.
+ */
+
+// jsonBaseAST is a filter expression, it is evaluated once for every node
+// the filter selector is applied to.
 type jsonBaseAST interface {
-	Execute() JsonElement
+	Execute(ctx *jpathContext, current *jpathNode) jpathValue
+}
+
+// jsonSelectorAST selects children (or the node itself) of a node.
+type jsonSelectorAST interface {
+	selectNodes(ctx *jpathContext, node *jpathNode, out []*jpathNode) []*jpathNode
+}
+
+// $.a[0] or @.a[0], also used as expression inside filters.
+type jpathQueryAST struct {
+	relative bool // starts with '@'
+	segments []*jpathSegmentAST
+}
+
+type jpathSegmentAST struct {
+	descendant bool // '..'
+	selectors  []jsonSelectorAST
+}
+
+type jpathNameAST struct {
+	name string
+}
+
+type jpathWildcardAST struct {
+}
+
+type jpathIndexAST struct {
+	index int64
+}
+
+type jpathSliceAST struct {
+	start *int64
+	end   *int64
+	step  *int64
+}
+
+type jpathFilterAST struct {
+	expr jsonBaseAST
+}
+
+type jpathOrAST struct {
+	operands []jsonBaseAST
+}
+
+type jpathAndAST struct {
+	operands []jsonBaseAST
 }
 
-type jsonCellSlotAST struct {
-	vfloat  float64
-	vint    int64
-	vstring string
+type jpathNotAST struct {
+	operand jsonBaseAST
 }
 
-type jsonDictAST struct {
-	vmap map[string]*JsonElement
+type jpathCompareAST struct {
+	op    string
+	left  jsonBaseAST
+	right jsonBaseAST
 }
 
-type jsonArrayAST struct {
-	varray []*JsonElement
+type jpathLiteralAST struct {
+	value JsonElement
 }
 
-type jsonBoolAST struct {
-	vbool bool
+type jpathFunctionAST struct {
+	name string
+	fn   *jpathFunction
+	args []jsonBaseAST
 }
@@ -0,0 +1,579 @@
+package njson
+
+import (
+	"fmt"
+	"math"
+	"math/big"
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+/*
+ * UnmarshalTypeError is returned when an element can not be stored in the
+ * go value at Path, e.g. "servers[2].port: expected integer, got string".
+ */
+type UnmarshalTypeError struct {
+	Path     string
+	Expected string
+	Got      string
+}
+
+func (self *UnmarshalTypeError) Error() string {
+	msg := "expected " + self.Expected + ", got " + self.Got
+
+	if self.Path == "" {
+		return msg
+	}
+	return self.Path + ": " + msg
+}
+
+var elementTypeType = reflect.TypeOf((*JsonElement)(nil)).Elem()
+
+func elementTypeName(element JsonElement) string {
+	switch element.Type() {
+
+	case ELE_INTEGER:
+		return "integer"
+	case ELE_FLOAT:
+		return "float"
+	case ELE_STRING:
+		return "string"
+	case ELE_ARRAY:
+		return "array"
+	case ELE_DICT:
+		return "dict"
+	case ELE_BOOL:
+		return "bool"
+	case ELE_NULL:
+		return "null"
+	}
+
+	return "unknown"
+}
+
+type structField struct {
+	name      string
+	index     []int
+	omitEmpty bool
+}
+
+/*
+ * structFields lists the fields of a struct type with their json names,
+ * taken from the `njson:"name,omitempty"` tag or the field name.
+ * fields of embedded structs and struct pointers are listed as if they
+ * were in t itself, with the rules of encoding/json: the shallowest field
+ * of a name wins, and a name found more than once at that depth is left
+ * out unless exactly one of them is tagged.
+ */
+func structFields(t reflect.Type) []structField {
+	type embeddedStruct struct {
+		t     reflect.Type
+		index []int
+	}
+	type candidate struct {
+		field  structField
+		tagged bool
+	}
+
+	fields := []structField{}
+	taken := map[string]bool{}         // names found at a shallower depth
+	visited := map[reflect.Type]bool{} // struct types walked at a shallower depth
+
+	current := []embeddedStruct{{t, nil}}
+
+	for len(current) > 0 {
+		next := []embeddedStruct{}
+		found := []candidate{}
+		count := map[string]int{}
+		tagged := map[string]int{}
+
+		for _, s := range current {
+			if visited[s.t] {
+				continue
+			}
+
+			for i := 0; i < s.t.NumField(); i++ {
+				f := s.t.Field(i)
+				tag := f.Tag.Get("njson")
+
+				if tag == "-" {
+					continue
+				}
+
+				fieldIndex := make([]int, len(s.index)+1)
+				copy(fieldIndex, s.index)
+				fieldIndex[len(s.index)] = i
+
+				parts := strings.Split(tag, ",")
+				name := parts[0]
+
+				if f.Anonymous && name == "" {
+					ft := f.Type
+					if ft.Kind() == reflect.Ptr {
+						// an embedded *T of an unexported T can not be allocated
+						if f.PkgPath != "" {
+							continue
+						}
+						ft = ft.Elem()
+					}
+					if ft.Kind() == reflect.Struct {
+						next = append(next, embeddedStruct{ft, fieldIndex})
+						continue
+					}
+				}
+
+				if f.PkgPath != "" { // unexported
+					continue
+				}
+
+				c := candidate{tagged: name != ""}
+				if name == "" {
+					name = f.Name
+				}
+				if taken[name] {
+					continue
+				}
+
+				c.field = structField{
+					name:  name,
+					index: fieldIndex,
+				}
+				for _, opt := range parts[1:] {
+					if opt == "omitempty" {
+						c.field.omitEmpty = true
+					}
+				}
+
+				found = append(found, c)
+				count[name]++
+				if c.tagged {
+					tagged[name]++
+				}
+			}
+		}
+
+		for _, c := range found {
+			name := c.field.name
+			if count[name] == 1 || (tagged[name] == 1 && c.tagged) {
+				fields = append(fields, c.field)
+			}
+		}
+		for name := range count {
+			taken[name] = true
+		}
+		for _, s := range current {
+			visited[s.t] = true
+		}
+
+		current = next
+	}
+
+	return fields
+}
+
+func findField(fields []structField, key string) *structField {
+	for i := range fields {
+		if fields[i].name == key {
+			return &fields[i]
+		}
+	}
+
+	for i := range fields {
+		if strings.EqualFold(fields[i].name, key) {
+			return &fields[i]
+		}
+	}
+
+	return nil
+}
+
+type unmarshaler struct {
+}
+
+func (self *unmarshaler) typeError(path string, expected string, element JsonElement) error {
+	return &UnmarshalTypeError{
+		Path:     path,
+		Expected: expected,
+		Got:      elementTypeName(element),
+	}
+}
+
+func (self *unmarshaler) decode(element JsonElement, v reflect.Value, path string) error {
+	if v.Type() == elementTypeType {
+		v.Set(reflect.ValueOf(element))
+		return nil
+	}
+
+	// *JsonDictElement, *JsonArrayElement... take the element itself
+	if v.Kind() == reflect.Ptr && v.Type().Implements(elementTypeType) {
+		ev := reflect.ValueOf(element)
+
+		switch {
+		case ev.Type().AssignableTo(v.Type()):
+			v.Set(ev)
+		case element.Type() == ELE_NULL:
+			v.Set(reflect.Zero(v.Type()))
+		default:
+			expected := reflect.New(v.Type().Elem()).Interface().(JsonElement)
+			return self.typeError(path, elementTypeName(expected), element)
+		}
+		return nil
+	}
+
+	if v.Kind() == reflect.Ptr {
+		if element.Type() == ELE_NULL {
+			v.Set(reflect.Zero(v.Type()))
+			return nil
+		}
+
+		if v.IsNil() {
+			v.Set(reflect.New(v.Type().Elem()))
+		}
+		return self.decode(element, v.Elem(), path)
+	}
+
+	if element.Type() == ELE_NULL {
+		if v.Kind() == reflect.Interface || v.Kind() == reflect.Map || v.Kind() == reflect.Slice {
+			v.Set(reflect.Zero(v.Type()))
+		}
+		return nil
+	}
+
+	// the fields of an element are unexported, there is nothing to decode them into
+	if reflect.PtrTo(v.Type()).Implements(elementTypeType) {
+		return pathError(path, "can not unmarshal into a %s value, its fields are unexported", v.Type())
+	}
+
+	switch v.Type() {
+
+	case jsonObjectType:
+		dict, ok := element.(*JsonDictElement)
+		if !ok {
+			return self.typeError(path, "dict", element)
+		}
+		v.Set(reflect.ValueOf(JsonObject{_dict: dict}))
+		return nil
+
+	case numberType:
+		if !isNumber(element) {
+			return self.typeError(path, "number", element)
+		}
+		v.SetString(numberString(element))
+		return nil
+
+	case bigIntType:
+		var n *big.Int
+		switch element.Type() {
+		case ELE_INTEGER:
+			n = integerBig(element)
+		case ELE_FLOAT:
+			if f, ok := element.(*JsonFloatElement); ok {
+				n = f.ToBigInt()
+			}
+		}
+		if n == nil {
+			return self.typeError(path, "integer", element)
+		}
+		v.Addr().Interface().(*big.Int).Set(n)
+		return nil
+
+	case bigFloatType:
+		if !isNumber(element) {
+			return self.typeError(path, "number", element)
+		}
+		f := numberBigFloat(element)
+		if f == nil {
+			return pathError(path, "value %s is not a number", element)
+		}
+		v.Addr().Interface().(*big.Float).Set(f)
+		return nil
+	}
+
+	switch v.Kind() {
+
+	case reflect.Bool:
+		if element.Type() != ELE_BOOL {
+			return self.typeError(path, "bool", element)
+		}
+		v.SetBool(element.ToBool())
+
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		if element.Type() != ELE_INTEGER {
+			return self.typeError(path, "integer", element)
+		}
+
+		if lexemeOf(element) != "" {
+			n := integerBig(element)
+			if !n.IsInt64() || v.OverflowInt(n.Int64()) {
+				return pathError(path, "value %s overflows %s", n, v.Type())
+			}
+		}
+
+		n := element.ToInteger64()
+		if v.OverflowInt(n) {
+			return pathError(path, "value %d overflows %s", n, v.Type())
+		}
+		v.SetInt(n)
+
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		if element.Type() != ELE_INTEGER {
+			return self.typeError(path, "integer", element)
+		}
+
+		if lexemeOf(element) != "" {
+			// may be above the int64 range
+			n := integerBig(element)
+			if n.Sign() < 0 || !n.IsUint64() || v.OverflowUint(n.Uint64()) {
+				return pathError(path, "value %s overflows %s", n, v.Type())
+			}
+			v.SetUint(n.Uint64())
+			break
+		}
+
+		n := element.ToInteger64()
+		if n < 0 || v.OverflowUint(uint64(n)) {
+			return pathError(path, "value %d overflows %s", n, v.Type())
+		}
+		v.SetUint(uint64(n))
+
+	case reflect.Float32, reflect.Float64:
+		var f float64
+
+		switch element.Type() {
+		case ELE_FLOAT:
+			f = element.ToFloat64()
+		case ELE_INTEGER:
+			f = float64(element.ToInteger64())
+		default:
+			return self.typeError(path, "number", element)
+		}
+
+		if lexemeOf(element) != "" {
+			f, _ = numberBigFloat(element).Float64()
+		}
+
+		if math.IsInf(f, 0) || v.OverflowFloat(f) {
+			return pathError(path, "value %v overflows %s", f, v.Type())
+		}
+		v.SetFloat(f)
+
+	case reflect.String:
+		if element.Type() != ELE_STRING {
+			return self.typeError(path, "string", element)
+		}
+		v.SetString(element.ToString())
+
+	case reflect.Interface:
+		if v.NumMethod() != 0 {
+			return pathError(path, "can not unmarshal into %s", v.Type())
+		}
+		if x := toInterface(element); x != nil {
+			v.Set(reflect.ValueOf(x))
+		} else {
+			v.Set(reflect.Zero(v.Type()))
+		}
+
+	case reflect.Slice:
+		if element.Type() != ELE_ARRAY {
+			return self.typeError(path, "array", element)
+		}
+
+		items := element.ToElementArray()
+		slice := reflect.MakeSlice(v.Type(), len(items), len(items))
+
+		for i, item := range items {
+			if err := self.decode(item, slice.Index(i), path+"["+strconv.Itoa(i)+"]"); err != nil {
+				return err
+			}
+		}
+		v.Set(slice)
+
+	case reflect.Array:
+		if element.Type() != ELE_ARRAY {
+			return self.typeError(path, "array", element)
+		}
+
+		items := element.ToElementArray()
+		for i := 0; i < v.Len(); i++ {
+			if i >= len(items) {
+				v.Index(i).Set(reflect.Zero(v.Type().Elem()))
+				continue
+			}
+			if err := self.decode(items[i], v.Index(i), path+"["+strconv.Itoa(i)+"]"); err != nil {
+				return err
+			}
+		}
+
+	case reflect.Map:
+		if element.Type() != ELE_DICT {
+			return self.typeError(path, "dict", element)
+		}
+		return self.decodeMap(element, v, path)
+
+	case reflect.Struct:
+		if element.Type() != ELE_DICT {
+			return self.typeError(path, "dict", element)
+		}
+		return self.decodeStruct(element, v, path)
+
+	default:
+		return pathError(path, "can not unmarshal into %s", v.Type())
+	}
+
+	return nil
+}
+
+func pathError(path string, format string, a ...interface{}) error {
+	msg := fmt.Sprintf(format, a...)
+
+	if path == "" {
+		return fmt.Errorf("%s", msg)
+	}
+	return fmt.Errorf("%s: %s", path, msg)
+}
+
+func joinPath(path string, key string) string {
+	if path == "" {
+		return key
+	}
+	return path + "." + key
+}
+
+func (self *unmarshaler) decodeMap(element JsonElement, v reflect.Value, path string) error {
+	t := v.Type()
+	dict := element.ToDict()
+
+	if v.IsNil() {
+		v.Set(reflect.MakeMapWithSize(t, len(dict)))
+	}
+
+	for _, k := range dictKeys(element) {
+		key := reflect.New(t.Key()).Elem()
+
+		switch t.Key().Kind() {
+		case reflect.String:
+			key.SetString(k)
+
+		case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+			n, err := strconv.ParseInt(k, 10, 64)
+			if err != nil || key.OverflowInt(n) {
+				return pathError(path, "invalid %s map key '%s'", t.Key(), k)
+			}
+			key.SetInt(n)
+
+		case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+			n, err := strconv.ParseUint(k, 10, 64)
+			if err != nil || key.OverflowUint(n) {
+				return pathError(path, "invalid %s map key '%s'", t.Key(), k)
+			}
+			key.SetUint(n)
+
+		default:
+			return pathError(path, "unsupported map key type %s", t.Key())
+		}
+
+		value := reflect.New(t.Elem()).Elem()
+		if err := self.decode(dict[k], value, joinPath(path, k)); err != nil {
+			return err
+		}
+		v.SetMapIndex(key, value)
+	}
+
+	return nil
+}
+
+// allocFieldValue returns the field at index, the nil embedded struct pointers on the way are allocated.
+func allocFieldValue(v reflect.Value, index []int) reflect.Value {
+	for i, x := range index {
+		if i > 0 && v.Kind() == reflect.Ptr {
+			if v.IsNil() {
+				v.Set(reflect.New(v.Type().Elem()))
+			}
+			v = v.Elem()
+		}
+		v = v.Field(x)
+	}
+	return v
+}
+
+func (self *unmarshaler) decodeStruct(element JsonElement, v reflect.Value, path string) error {
+	fields := structFields(v.Type())
+	dict := element.ToDict()
+
+	for _, k := range dictKeys(element) {
+		field := findField(fields, k)
+		if field == nil {
+			continue
+		}
+
+		fv := allocFieldValue(v, field.index)
+		if err := self.decode(dict[k], fv, joinPath(path, k)); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+/*
+ * toInterface converts element to plain go values: map[string]interface{},
+ * []interface{}, string, int64, float64, bool or nil.
+ */
+func toInterface(element JsonElement) interface{} {
+	switch element.Type() {
+
+	case ELE_DICT:
+		dict := element.ToDict()
+		m := make(map[string]interface{}, len(dict))
+		for k, v := range dict {
+			m[k] = toInterface(v)
+		}
+		return m
+
+	case ELE_ARRAY:
+		items := element.ToElementArray()
+		a := make([]interface{}, len(items))
+		for i, v := range items {
+			a[i] = toInterface(v)
+		}
+		return a
+
+	case ELE_STRING:
+		return element.ToString()
+	case ELE_INTEGER, ELE_FLOAT:
+		if raw := lexemeOf(element); raw != "" {
+			return Number(raw)
+		}
+		if element.Type() == ELE_INTEGER {
+			return element.ToInteger64()
+		}
+		return element.ToFloat64()
+	case ELE_BOOL:
+		return element.ToBool()
+	}
+
+	return nil
+}
+
+/*
+ * Unmarshal stores element in the value v points to. dicts fill structs
+ * (fields are matched by `njson:"name"` tag or field name) and maps, arrays
+ * fill slices and arrays, null sets pointers, maps and slices to nil.
+ * an interface{} receives plain go values, a JsonElement or an element
+ * pointer like *JsonDictElement the element itself, a JsonObject the dict.
+ */
+func Unmarshal(element JsonElement, v interface{}) error {
+	rv := reflect.ValueOf(v)
+
+	if rv.Kind() != reflect.Ptr || rv.IsNil() {
+		return fmt.Errorf("Unmarshal needs a non-nil pointer, got %T", v)
+	}
+
+	if element == nil {
+		return fmt.Errorf("can not unmarshal nil element")
+	}
+
+	u := &unmarshaler{}
+	return u.decode(element, rv.Elem(), "")
+}
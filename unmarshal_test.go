@@ -0,0 +1,229 @@
+package njson
+
+import (
+	"reflect"
+	"testing"
+)
+
+type embeddedBase struct {
+	ID   int    `njson:"id"`
+	Kind string `njson:"kind"`
+}
+
+type embeddingService struct {
+	*embeddedBase
+	Name string `njson:"name"`
+}
+
+type EmbeddedBase struct {
+	ID int `njson:"id"`
+}
+
+type embeddingExported struct {
+	*EmbeddedBase
+	Name string `njson:"name"`
+}
+
+func TestUnmarshalEmbeddedPointer(t *testing.T) {
+	element, err := LoadsValue(`{"id": 7, "name": "svc"}`)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var v embeddingExported
+	if err := Unmarshal(element, &v); err != nil {
+		t.Fatal(err)
+	}
+	if v.EmbeddedBase == nil || v.ID != 7 || v.Name != "svc" {
+		t.Errorf("got %+v", v)
+	}
+
+	// nothing of the embedded struct in the input, the pointer stays nil
+	element, _ = LoadsValue(`{"name": "svc"}`)
+	v = embeddingExported{}
+	if err := Unmarshal(element, &v); err != nil {
+		t.Fatal(err)
+	}
+	if v.EmbeddedBase != nil {
+		t.Errorf("embedded pointer allocated without a field to decode")
+	}
+
+	// an embedded pointer to an unexported type is ignored
+	element, _ = LoadsValue(`{"id": 7, "name": "svc"}`)
+	var u embeddingService
+	if err := Unmarshal(element, &u); err != nil {
+		t.Fatal(err)
+	}
+	if u.embeddedBase != nil || u.Name != "svc" {
+		t.Errorf("got %+v", u)
+	}
+}
+
+func TestMarshalEmbeddedPointer(t *testing.T) {
+	cases := []struct {
+		value interface{}
+		want  string
+	}{
+		{embeddingExported{EmbeddedBase: &EmbeddedBase{ID: 7}, Name: "svc"}, `{"name":"svc","id":7}`},
+		{embeddingExported{Name: "svc"}, `{"name":"svc"}`},
+	}
+
+	for _, c := range cases {
+		element, err := Marshal(c.value)
+		if err != nil {
+			t.Fatal(err)
+		}
+		s, _ := Dumps(element)
+		if s != c.want {
+			t.Errorf("Marshal(%+v) = %s, want %s", c.value, s, c.want)
+		}
+	}
+}
+
+type deepX struct {
+	X int `njson:"x"`
+}
+
+type embedsDeep struct {
+	deepX
+}
+
+type hasX struct {
+	X int `njson:"x"`
+}
+
+type shallowWins struct {
+	embedsDeep
+	hasX
+}
+
+type taggedX struct {
+	X int `njson:"x"`
+}
+
+type untaggedX struct {
+	X int
+}
+
+type taggedUpperX struct {
+	X int `njson:"X"`
+}
+
+type ambiguousX struct {
+	hasX
+	taggedX
+}
+
+type taggedWins struct {
+	untaggedX
+	taggedUpperX
+	Y int `njson:"y"`
+}
+
+type selfEmbedding struct {
+	*selfEmbedding
+	Name string `njson:"name"`
+}
+
+type SelfEmbedding struct {
+	*SelfEmbedding
+	Name string `njson:"name"`
+}
+
+func TestEmbeddedFieldPrecedence(t *testing.T) {
+	dumps := func(element JsonElement) string {
+		s, _ := Dumps(element)
+		return s
+	}
+
+	element, err := LoadsValue(`{"x": 5, "y": 7, "name": "n"}`)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// the field at depth 1 wins over the one at depth 2
+	var s shallowWins
+	if err := Unmarshal(element, &s); err != nil {
+		t.Fatal(err)
+	}
+	if s.hasX.X != 5 || s.embedsDeep.X != 0 {
+		t.Errorf("got %+v", s)
+	}
+	s = shallowWins{embedsDeep{deepX{1}}, hasX{2}}
+	if got, _ := Marshal(s); dumps(got) != `{"x":2}` {
+		t.Errorf("Marshal(%+v) = %s", s, dumps(got))
+	}
+
+	// two tagged fields at the same depth, the name is left out
+	var a ambiguousX
+	if err := Unmarshal(element, &a); err != nil {
+		t.Fatal(err)
+	}
+	if a.hasX.X != 0 || a.taggedX.X != 0 {
+		t.Errorf("got %+v", a)
+	}
+	if got, _ := Marshal(ambiguousX{hasX{1}, taggedX{2}}); dumps(got) != `{}` {
+		t.Errorf("Marshal(ambiguousX) = %s", dumps(got))
+	}
+
+	// the tagged field wins over the untagged one of the same depth
+	fields := structFields(reflect.TypeOf(taggedWins{}))
+	if len(fields) != 2 || fields[0].name != "y" || !reflect.DeepEqual(fields[1].index, []int{1, 0}) {
+		t.Errorf("fields of taggedWins: %+v", fields)
+	}
+
+	// a struct embedding a pointer to itself is walked once
+	var e SelfEmbedding
+	if err := Unmarshal(element, &e); err != nil {
+		t.Fatal(err)
+	}
+	if e.Name != "n" || e.SelfEmbedding != nil {
+		t.Errorf("got %+v", e)
+	}
+	if len(structFields(reflect.TypeOf(selfEmbedding{}))) != 1 {
+		t.Errorf("fields of selfEmbedding: %v", structFields(reflect.TypeOf(selfEmbedding{})))
+	}
+}
+
+func TestUnmarshalElements(t *testing.T) {
+	element, err := LoadsValue(`{"d": {"a": 1}, "l": [1, 2], "n": null, "s": "x", "o": {"b": 2}}`)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var v struct {
+		D   *JsonDictElement  `njson:"d"`
+		L   *JsonArrayElement `njson:"l"`
+		N   *JsonDictElement  `njson:"n"`
+		Obj JsonObject        `njson:"o"`
+	}
+	v.N = NewJsonDictElement()
+	if err := Unmarshal(element, &v); err != nil {
+		t.Fatal(err)
+	}
+	d := element.ToDict()
+	if v.D != d["d"] || v.L != d["l"] || v.N != nil || v.Obj.ToDictElement() != d["o"] {
+		t.Errorf("got %+v", v)
+	}
+
+	var obj JsonObject
+	if err := Unmarshal(element, &obj); err != nil || obj.ToDictElement() != element {
+		t.Errorf("JsonObject: %v", err)
+	}
+
+	var wrong struct {
+		S *JsonDictElement `njson:"s"`
+	}
+	err = Unmarshal(element, &wrong)
+	if e, ok := err.(*UnmarshalTypeError); !ok || e.Path != "s" || e.Expected != "dict" {
+		t.Errorf("got %v", err)
+	}
+	if err := Unmarshal(NewJsonStringElement("x"), &obj); err == nil {
+		t.Errorf("a string decoded into a JsonObject")
+	}
+
+	// the fields of an element can not be filled
+	if err := Unmarshal(element, NewJsonDictElement()); err == nil {
+		t.Errorf("decoded into the fields of a JsonDictElement")
+	}
+}